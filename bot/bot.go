@@ -0,0 +1,245 @@
+// Package bot drives server side players. A bot wanders the arena, chases
+// the closest enemy it sees and shoots at it, and gets out of the way of
+// incoming bullets. In capture the flag it goes for the flags when nobody
+// is around and runs home once it has one.
+package bot
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/maxxxlounge/websocket/game"
+
+	"github.com/faiface/pixel"
+	guuid "github.com/google/uuid"
+)
+
+// DodgeTime is how far ahead bots look for bullets, DodgeDistance how
+// close a bullet has to pass to be dodged.
+const DodgeTime float64 = 1
+const DodgeDistance float64 = 24
+
+// KeepDistance is how close bots get to their target before they stop
+// and only turn towards it.
+const KeepDistance float64 = 120
+
+// WanderMargin keeps wander targets off the edges of the arena.
+const WanderMargin float64 = 50
+
+type Bot struct {
+	ID         guuid.UUID
+	difficulty Difficulty
+	rng        *rand.Rand
+	// think is the time until the next decision, input the last one
+	think  float64
+	input  game.Input
+	wander pixel.Vec
+	// wanderTime is the time left to reach wander before picking another
+	wanderTime float64
+}
+
+func New(id guuid.UUID, d Difficulty) *Bot {
+	return &Bot{
+		ID:         id,
+		difficulty: d,
+		rng:        rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// Update returns the controls of the bot's player for the next dt seconds.
+// Between two decisions the bot keeps its controls, like a human would.
+func (b *Bot) Update(g *game.Game, dt float64) game.Input {
+	p := g.GetPlayer(b.ID)
+	if p == nil || p.Life <= 0 {
+		b.think = 0
+		return game.Input{}
+	}
+	b.wanderTime -= dt
+	b.think -= dt
+	if b.think > 0 {
+		return b.input
+	}
+	b.think = b.difficulty.Reaction * (0.5 + b.rng.Float64())
+	b.input = b.decide(g, p)
+	return b.input
+}
+
+func (b *Bot) decide(g *game.Game, p *game.Player) game.Input {
+	pos := pixel.V(p.X, p.Y)
+	if b.difficulty.Dodge {
+		if away, ok := b.dodge(g, p, pos); ok {
+			return control(away, false)
+		}
+	}
+	if g.Status == game.Playing {
+		goal, hasGoal := objective(g, p)
+		// flag carriers and bots out of the hill don't stop to fight
+		if hasGoal && (g.CarriedFlag(p) != nil || g.Zone != nil) {
+			return control(pos.To(goal).Unit(), false)
+		}
+		if target := b.enemy(g, p, pos); target != nil {
+			to := pos.To(pixel.V(target.X, target.Y))
+			aim := b.aim(p, target, to)
+			thrust := aim
+			if to.Len() < KeepDistance {
+				// just enough to face the target
+				thrust = aim.Scaled(0.05)
+			}
+			return control(thrust, to.Len() <= b.difficulty.FireRange)
+		}
+		if hasGoal && pos.To(goal) != pixel.ZV {
+			return control(pos.To(goal).Unit(), false)
+		}
+	}
+	return control(b.roam(g, pos), false)
+}
+
+// objective is where p should go: back into a shrinking arena, into the
+// hill or, in capture the flag, home with a flag, to its own flag lying
+// around or to an enemy flag nobody of its team carries yet.
+func objective(g *game.Game, p *game.Player) (pixel.Vec, bool) {
+	pos := pixel.V(p.X, p.Y)
+	if !g.Bounds.Contains(pos) {
+		return g.Bounds.Centre(), true
+	}
+	if z := g.Zone; z != nil {
+		centre := pixel.V(z.X, z.Y)
+		return centre, pos.To(centre).Len() > z.Radius/2
+	}
+	if len(g.Flags) == 0 {
+		return pixel.ZV, false
+	}
+	if g.CarriedFlag(p) != nil {
+		return g.Base(p.Team), true
+	}
+	if own := g.Flag(p.Team); own != nil && own.Carrier == nil && !own.AtBase() {
+		return pixel.V(own.X, own.Y), true
+	}
+	for _, f := range g.Flags {
+		if f.Team == p.Team || (f.Carrier != nil && g.Allies(f.Carrier, p)) {
+			continue
+		}
+		return pixel.V(f.X, f.Y), true
+	}
+	return pixel.ZV, false
+}
+
+func control(thrust pixel.Vec, fire bool) game.Input {
+	return game.Input{AxisX: thrust.X, AxisY: thrust.Y, Fire: fire}
+}
+
+// enemy returns the closest living player b can see that can be hurt.
+func (b *Bot) enemy(g *game.Game, p *game.Player, pos pixel.Vec) *game.Player {
+	var best *game.Player
+	bestDist := math.Inf(1)
+	for _, o := range g.PlayersNear(pos, b.difficulty.Sight) {
+		if o == p || o.Invulnerable > 0 || g.Allies(o, p) {
+			continue
+		}
+		if d := pos.To(pixel.V(o.X, o.Y)).Len(); d < bestDist {
+			best, bestDist = o, d
+		}
+	}
+	return best
+}
+
+// aim returns the unit direction to shoot at target, to is the way from p
+// to it. With Lead the bot aims where its bullet meets the target.
+func (b *Bot) aim(p, target *game.Player, to pixel.Vec) pixel.Vec {
+	dir := to
+	if w := p.Weapon(); b.difficulty.Lead && w != nil && w.Speed > 0 {
+		// bullets inherit the velocity of the ship, so lead relative to it
+		if t, ok := intercept(to, target.Velocity.Sub(p.Velocity), w.Speed); ok {
+			dir = to.Add(target.Velocity.Sub(p.Velocity).Scaled(t))
+		}
+	}
+	if dir == pixel.ZV {
+		return pixel.V(0, 1)
+	}
+	return dir.Unit().Rotated((b.rng.Float64()*2 - 1) * b.difficulty.AimError)
+}
+
+// intercept returns the time a bullet of speed fired now meets a target
+// at to moving with velocity v.
+func intercept(to, v pixel.Vec, speed float64) (float64, bool) {
+	a := v.Dot(v) - speed*speed
+	bb := 2 * to.Dot(v)
+	c := to.Dot(to)
+	if math.Abs(a) < 1e-9 {
+		if bb >= 0 {
+			return 0, false
+		}
+		return -c / bb, true
+	}
+	disc := bb*bb - 4*a*c
+	if disc < 0 {
+		return 0, false
+	}
+	sq := math.Sqrt(disc)
+	t1, t2 := (-bb-sq)/(2*a), (-bb+sq)/(2*a)
+	if t1 > t2 {
+		t1, t2 = t2, t1
+	}
+	if t1 > 0 {
+		return t1, true
+	}
+	if t2 > 0 {
+		return t2, true
+	}
+	return 0, false
+}
+
+// dodge returns the way out of the path of the first enemy bullet that
+// would pass within DodgeDistance in the next DodgeTime seconds.
+func (b *Bot) dodge(g *game.Game, p *game.Player, pos pixel.Vec) (pixel.Vec, bool) {
+	var away pixel.Vec
+	first := DodgeTime
+	for _, bu := range g.BulletsNear(pos, b.difficulty.Sight) {
+		if bu.Owner == p.UUID || bu.Exhausted {
+			continue
+		}
+		if o := g.GetPlayer(bu.Owner); o != nil && !g.CanHurt(o, p) {
+			continue
+		}
+		d := pos.To(pixel.V(bu.X, bu.Y))
+		v := bu.Velocity.Sub(p.Velocity)
+		speed := v.Dot(v)
+		if speed == 0 {
+			continue
+		}
+		t := -d.Dot(v) / speed
+		if t < 0 || t > first {
+			continue
+		}
+		closest := d.Add(v.Scaled(t))
+		if closest.Len() > DodgeDistance {
+			continue
+		}
+		first = t
+		if closest.Len() < 1 {
+			// dead on, either side will do
+			away = v.Unit().Normal()
+		} else {
+			away = closest.Unit().Scaled(-1)
+		}
+	}
+	return away, away != pixel.ZV
+}
+
+// roam heads for a random point of the arena and picks another one once
+// it got there or took too long.
+func (b *Bot) roam(g *game.Game, pos pixel.Vec) pixel.Vec {
+	if b.wanderTime <= 0 || pos.To(b.wander).Len() < WanderMargin {
+		w, h := g.Bounds.Width-2*WanderMargin, g.Bounds.Height-2*WanderMargin
+		b.wander = pixel.V(
+			g.Bounds.X+WanderMargin+b.rng.Float64()*math.Max(0, w),
+			g.Bounds.Y+WanderMargin+b.rng.Float64()*math.Max(0, h),
+		)
+		b.wanderTime = 5 + 5*b.rng.Float64()
+	}
+	to := pos.To(b.wander)
+	if to == pixel.ZV {
+		return pixel.ZV
+	}
+	return to.Unit().Scaled(0.6)
+}
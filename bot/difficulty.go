@@ -0,0 +1,32 @@
+package bot
+
+import "github.com/pkg/errors"
+
+// Difficulty tunes how well a bot plays. Reaction is the average time
+// between two decisions, AimError the largest aim offset in radians. Bots
+// see enemies within Sight and shoot at them within FireRange. Lead makes
+// them aim where the target will be and Dodge makes them evade bullets.
+type Difficulty struct {
+	Name      string
+	Reaction  float64
+	AimError  float64
+	Sight     float64
+	FireRange float64
+	Lead      bool
+	Dodge     bool
+}
+
+var Easy = Difficulty{Name: "easy", Reaction: 0.4, AimError: 0.35, Sight: 300, FireRange: 250}
+var Normal = Difficulty{Name: "normal", Reaction: 0.2, AimError: 0.15, Sight: 450, FireRange: 350, Lead: true, Dodge: true}
+var Hard = Difficulty{Name: "hard", Reaction: 0.08, AimError: 0.04, Sight: 600, FireRange: 450, Lead: true, Dodge: true}
+
+var Difficulties = []Difficulty{Easy, Normal, Hard}
+
+func ParseDifficulty(s string) (Difficulty, error) {
+	for _, d := range Difficulties {
+		if d.Name == s {
+			return d, nil
+		}
+	}
+	return Difficulty{}, errors.Errorf("unknown bot difficulty %q", s)
+}
@@ -1,37 +1,49 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	_ "image/png"
+	"math"
 	"net/url"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/faiface/pixel/text"
 	"golang.org/x/image/font/basicfont"
 
 	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
 	guuid "github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/maxxxlounge/websocket/game"
+	"github.com/maxxxlounge/websocket/protocol"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/image/colornames"
 )
 
 type CustomConn struct {
-	Conn *websocket.Conn
-	ID   guuid.UUID
+	Conn    *websocket.Conn
+	ID      guuid.UUID
+	Decoder *protocol.BinaryDecoder
 }
 
 var bulletSprite *pixel.Sprite
 var sprite *pixel.Sprite
 
+// arena is the map of the current room, it is sent once on join.
+var arena *protocol.Map
+
+// tickRate is the simulation rate of the current room's server.
+var tickRate = game.DefaultTickRate
+
 type Player struct {
-	*game.Player
+	*protocol.Player
 	Sprite *pixel.Sprite
 	ID     guuid.UUID
 }
@@ -49,23 +61,47 @@ func loadPicture(path string) (pixel.Picture, error) {
 	return pixel.PictureDataFromImage(img), nil
 }
 
-func ReceiveMessage(g *game.Game, conn *CustomConn) {
-	_, message, err := conn.Conn.ReadMessage()
+func ReceiveMessage(g *protocol.Frame, conn *CustomConn) {
+	mType, message, err := conn.Conn.ReadMessage()
 	if err != nil {
 		log.Println("read:", err)
 		return
 	}
 	//log.Printf("recv: %s", message)
-	err = json.Unmarshal(message, &g)
+	if mType == websocket.BinaryMessage {
+		frame, err := conn.Decoder.Decode(message)
+		if err != nil {
+			err = errors.Wrap(err, "error decoding snapshot")
+			log.Error(err)
+			return
+		}
+		*g = *frame
+		SendMessage(conn, protocol.NewAck(frame.Epoch, frame.World.Tick))
+		return
+	}
+	msg, err := protocol.DecodeServer(message)
 	if err != nil {
-		err = errors.Wrap(err, "error unmarshalling game object")
+		err = errors.Wrap(err, "error decoding server message")
 		log.Error(err)
 		return
 	}
+	switch m := msg.(type) {
+	case *protocol.Frame:
+		*g = *m
+	case *protocol.Map:
+		arena = m
+	case *protocol.Joined:
+		log.Printf("joined room %s (%s)", m.Room.Name, m.Room.ID)
+		if m.Room.TickRate > 0 {
+			tickRate = m.Room.TickRate
+		}
+	case *protocol.Error:
+		log.Errorf("server error %s: %s", m.Code, m.Message)
+	}
 }
 
 func Run(conn *CustomConn) {
-	var g game.Game
+	var g protocol.Frame
 	basicAtlas := text.NewAtlas(basicfont.Face7x13, text.ASCII)
 	Formatter := new(log.TextFormatter)
 	Formatter.TimestampFormat = "02-01-2006 15:04:05"
@@ -99,109 +135,343 @@ func Run(conn *CustomConn) {
 	bgsprite := pixel.NewSprite(bg, bg.Bounds())
 	bulletSprite = pixel.NewSprite(bullet, bullet.Bounds())
 
+	sent := protocol.NewInput(0)
+	weapon := ""
+	predictor := NewPredictor()
+	// held is how long the last input sent is held. It is sent again with
+	// a new seq every tick: the server acknowledges the last seq it
+	// applied, so the replay is only as exact as the time a seq covers.
+	held := 0.0
+	last := time.Now()
 	for !win.Closed() {
+		dt := time.Since(last).Seconds()
+		last = time.Now()
 		win.Clear(colornames.Black)
 		bgsprite.Draw(win, pixel.IM.Moved(win.Bounds().Center()))
 		ReceiveMessage(&g, conn)
-		if g.You != nil {
-			if g.You.Life <= 0 {
-				log.Println("you died!")
-				return
-			}
-			if win.Pressed(pixelgl.KeyLeft) {
-				SendInput(conn, pixelgl.KeyLeft.String()+"down")
-			}
-			if win.JustReleased(pixelgl.KeyLeft) {
-				SendInput(conn, pixelgl.KeyLeft.String()+"up")
-			}
-			if win.Pressed(pixelgl.KeyRight) {
-				SendInput(conn, pixelgl.KeyRight.String()+"down")
-			}
-			if win.JustReleased(pixelgl.KeyRight) {
-				SendInput(conn, pixelgl.KeyRight.String()+"up")
-			}
-			if win.Pressed(pixelgl.KeyDown) {
-				SendInput(conn, pixelgl.KeyDown.String()+"down")
-			}
-			if win.JustReleased(pixelgl.KeyDown) {
-				SendInput(conn, pixelgl.KeyDown.String()+"up")
-			}
-			if win.Pressed(pixelgl.KeyUp) {
-				SendInput(conn, pixelgl.KeyUp.String()+"down")
-			}
-			if win.JustReleased(pixelgl.KeyUp) {
-				SendInput(conn, pixelgl.KeyUp.String()+"up")
-			}
-			if win.Pressed(pixelgl.KeySpace) {
-				SendInput(conn, "shoot")
+		predictor.Reconcile(&g)
+		// ship is the own ship where the predictor has it
+		var ship *protocol.Player
+		if you := g.World.Player(g.You); you != nil {
+			weapon = NextWeapon(win, you, weapon)
+			in := ReadInput(win, sent.Seq, weapon)
+			held += dt
+			if in != sent || held >= 1/float64(tickRate) {
+				in.Seq++
+				SendMessage(conn, in)
+				sent, held = in, 0
 			}
+			predictor.Apply(sent, dt)
+			shown := predictor.Show(*you)
+			ship = &shown
 		}
 
-		UpdateGame(win, &g, basicAtlas)
+		UpdateGame(win, &g, ship, basicAtlas)
+		DrawStatus(win, &g, basicAtlas)
 		win.Update()
 	}
 }
 
 func main() {
 	var err error
+	room := flag.String("room", "", "id of the room to join, any room if empty")
 	flag.Parse()
 
 	//interrupt := make(chan os.Signal, 1)
 	//signal.Notify(interrupt, os.Interrupt)
 
-	u := url.URL{Scheme: "ws", Host: "94.130.180.22:8888", Path: "/connect"}
+	u := url.URL{Scheme: "ws", Host: "94.130.180.22:8888", Path: "/connect", RawQuery: "encoding=" + protocol.EncodingBinary}
 	log.Printf("connecting to %s", u.String())
 
-	conn := &CustomConn{}
+	conn := &CustomConn{Decoder: protocol.NewBinaryDecoder()}
 	conn.Conn, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
 	if err != nil {
 		log.Fatal("dial:", err)
 	}
 	defer conn.Conn.Close()
+	SendMessage(conn, protocol.NewJoinRoom(*room))
 	//doing
 	pixelgl.Run(func() {
 		Run(conn)
 	})
 }
 
-func SendInput(c *CustomConn, input string) {
-	//log.Printf("client send command %s", input)
-	err := c.Conn.WriteMessage(websocket.TextMessage, []byte(input))
+// ReadInput samples the keyboard. seq is copied so the result can be
+// compared with the last input sent.
+func ReadInput(win *pixelgl.Window, seq uint32, weapon string) protocol.Input {
+	in := protocol.NewInput(seq)
+	in.Weapon = weapon
+	in.Left = win.Pressed(pixelgl.KeyLeft)
+	in.Right = win.Pressed(pixelgl.KeyRight)
+	in.Up = win.Pressed(pixelgl.KeyUp)
+	in.Down = win.Pressed(pixelgl.KeyDown)
+	in.Fire = win.Pressed(pixelgl.KeySpace)
+	return in
+}
+
+// NextWeapon cycles through the carried weapons with Q and E. weapon is
+// the current choice, it falls back to the server's when it is gone.
+func NextWeapon(win *pixelgl.Window, you *protocol.Player, weapon string) string {
+	current := -1
+	for i, w := range you.Weapons {
+		if w == weapon {
+			current = i
+		}
+	}
+	if current < 0 {
+		return you.Weapon
+	}
+	step := 0
+	if win.JustPressed(pixelgl.KeyQ) {
+		step = -1
+	}
+	if win.JustPressed(pixelgl.KeyE) {
+		step = 1
+	}
+	n := len(you.Weapons)
+	return you.Weapons[(current+step+n)%n]
+}
+
+func SendMessage(c *CustomConn, msg interface{}) {
+	data, err := protocol.Encode(msg)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	err = c.Conn.WriteMessage(websocket.TextMessage, data)
 	if err != nil {
 		log.Println("write:", err)
 		return
 	}
 }
 
-func UpdateGame(win *pixelgl.Window, g *game.Game, atlas *text.Atlas) {
+// UpdateGame draws the world of g around you, the own ship as predicted.
+func UpdateGame(win *pixelgl.Window, g *protocol.Frame, you *protocol.Player, atlas *text.Atlas) {
 	camPos := pixel.ZV
-	if g.You != nil {
-		camPos = pixel.V(g.You.X, g.You.Y)
+	if you != nil {
+		camPos = pixel.V(you.X, you.Y)
 		cam := pixel.IM.Scaled(camPos, 4).Moved(win.Bounds().Center().Sub(camPos))
 		win.SetMatrix(cam)
+		DrawArena(win)
+		DrawBounds(win, g)
 
-		mat := pixel.IM.Moved(pixel.V(g.You.X, g.You.Y))
-		mat = mat.Rotated(pixel.V(g.You.X, g.You.Y), float64(g.You.Rotation))
-		sprite.Draw(win, mat)
+		if Visible(you) {
+			mat := pixel.IM.Moved(pixel.V(you.X, you.Y))
+			mat = mat.Rotated(pixel.V(you.X, you.Y), you.Rotation)
+			sprite.DrawColorMask(win, mat, TeamColor(&g.World, you.Team))
+		}
 	}
 
-	for _, p := range g.Players {
-		if p != g.You {
-			if p.Life <= 0 {
+	for _, p := range g.World.Players {
+		if p.ID != g.You {
+			if !Visible(&p) {
 				continue
 			}
 			mat := pixel.IM.Moved(pixel.V(p.X, p.Y))
-			mat = mat.Rotated(pixel.V(p.X, p.Y), float64(p.Rotation))
-			sprite.Draw(win, mat)
+			mat = mat.Rotated(pixel.V(p.X, p.Y), p.Rotation)
+			sprite.DrawColorMask(win, mat, TeamColor(&g.World, p.Team))
 			basicTxt := text.New(pixel.V(p.X-3, p.Y+10), atlas)
 			fmt.Fprintf(basicTxt, fmt.Sprintf("%v", p.Life))
 			basicTxt.Draw(win, pixel.IM)
 		}
 	}
 
-	for _, b := range g.Bullets {
+	DrawPickups(win, g)
+	DrawFlags(win, g)
+	DrawZone(win, g)
+
+	for _, b := range g.World.Bullets {
 		mat := pixel.IM.Moved(pixel.V(b.X, b.Y))
-		mat = mat.Rotated(pixel.V(b.X, b.Y), float64(b.Rotation))
+		mat = mat.Rotated(pixel.V(b.X, b.Y), b.Rotation)
 		bulletSprite.Draw(win, mat)
 	}
 }
+
+// DrawArena draws the walls and asteroids of the map.
+func DrawArena(win *pixelgl.Window) {
+	if arena == nil {
+		return
+	}
+	imd := imdraw.New(nil)
+	imd.Color = colornames.Slategray
+	for _, w := range arena.Walls {
+		c := pixel.V(w.X, w.Y)
+		half := pixel.V(w.Width/2, w.Height/2)
+		for _, corner := range []pixel.Vec{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 1}, {X: -1, Y: 1}} {
+			imd.Push(c.Add(pixel.V(corner.X*half.X, corner.Y*half.Y).Rotated(w.Rotation)))
+		}
+		imd.Polygon(0)
+	}
+	imd.Color = colornames.Sienna
+	for _, a := range arena.Asteroids {
+		imd.Push(pixel.V(a.X, a.Y))
+		imd.Circle(a.Radius, 0)
+	}
+	imd.Draw(win)
+}
+
+// DrawBounds outlines the arena once a mode shrank it below the map.
+func DrawBounds(win *pixelgl.Window, g *protocol.Frame) {
+	b := g.World.Bounds
+	if arena == nil || (b.X == 0 && b.Y == 0 && b.Width == arena.Width && b.Height == arena.Height) {
+		return
+	}
+	imd := imdraw.New(nil)
+	imd.Color = colornames.Crimson
+	imd.Push(pixel.V(b.X, b.Y), pixel.V(b.X+b.Width, b.Y+b.Height))
+	imd.Rectangle(2)
+	imd.Draw(win)
+}
+
+// pickupColors tells the pickup kinds apart until they get sprites.
+var pickupColors = map[string]color.RGBA{
+	"health":     colornames.Limegreen,
+	"rapid_fire": colornames.Orange,
+	"damage":     colornames.Red,
+	"shield":     colornames.Deepskyblue,
+	"speed":      colornames.Yellow,
+	"weapon":     colornames.White,
+}
+
+func DrawPickups(win *pixelgl.Window, g *protocol.Frame) {
+	imd := imdraw.New(nil)
+	for _, k := range g.World.Pickups {
+		imd.Color = pickupColors[k.Kind]
+		if k.Kind == "weapon" {
+			imd.Push(pixel.V(k.X-5, k.Y-5), pixel.V(k.X+5, k.Y+5))
+			imd.Rectangle(2)
+			continue
+		}
+		imd.Push(pixel.V(k.X, k.Y))
+		imd.Circle(6, 2)
+	}
+	imd.Draw(win)
+}
+
+// DrawFlags draws the bases as rings and the flags as pennants in the
+// colour of their team.
+func DrawFlags(win *pixelgl.Window, g *protocol.Frame) {
+	imd := imdraw.New(nil)
+	for _, f := range g.World.Flags {
+		imd.Color = colornames.White
+		if c := TeamColor(&g.World, f.Team); c != nil {
+			imd.Color = c
+		}
+		imd.Push(pixel.V(f.BaseX, f.BaseY))
+		imd.Circle(32, 1)
+		imd.Push(pixel.V(f.X, f.Y-8), pixel.V(f.X, f.Y+8))
+		imd.Line(1)
+		imd.Push(pixel.V(f.X, f.Y+8), pixel.V(f.X+8, f.Y+5), pixel.V(f.X, f.Y+2))
+		imd.Polygon(0)
+	}
+	imd.Draw(win)
+}
+
+// DrawZone draws the hill of king of the hill.
+func DrawZone(win *pixelgl.Window, g *protocol.Frame) {
+	z := g.World.Zone
+	if z == nil {
+		return
+	}
+	imd := imdraw.New(nil)
+	imd.Color = colornames.Gold
+	imd.Push(pixel.V(z.X, z.Y))
+	imd.Circle(z.Radius, 2)
+	imd.Draw(win)
+}
+
+// TeamColor is the tint of the ships of team, nil leaves them as they are.
+func TeamColor(s *protocol.Snapshot, team int) color.Color {
+	for _, t := range s.Teams {
+		if t.ID != team {
+			continue
+		}
+		var c color.RGBA
+		if _, err := fmt.Sscanf(t.Color, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+			return nil
+		}
+		c.A = 255
+		return c
+	}
+	return nil
+}
+
+// Visible reports whether p is drawn this frame. Dead players are hidden,
+// invulnerable ones blink.
+func Visible(p *protocol.Player) bool {
+	if p.Life <= 0 {
+		return false
+	}
+	return p.Invulnerable <= 0 || int(p.Invulnerable*8)%2 == 0
+}
+
+// DrawStatus writes the match status in the top left corner of the window
+// and the scores while the scoreboard is shown.
+func DrawStatus(win *pixelgl.Window, g *protocol.Frame, atlas *text.Atlas) {
+	win.SetMatrix(pixel.IM)
+	txt := text.New(pixel.V(10, win.Bounds().H()-30), atlas)
+	left := int(math.Ceil(g.World.StatusTime))
+	switch g.World.Status {
+	case protocol.StatusWaitForPlayer:
+		fmt.Fprintln(txt, "waiting for players")
+	case protocol.StatusCountdown:
+		fmt.Fprintf(txt, "match starts in %d\n", left)
+	case protocol.StatusPlaying:
+		if left > 0 {
+			fmt.Fprintf(txt, "%d:%02d\n", left/60, left%60)
+		}
+		for _, t := range g.World.Teams {
+			fmt.Fprintf(txt, "%s %d  ", t.Name, t.Score)
+		}
+		if len(g.World.Teams) > 0 {
+			fmt.Fprintln(txt)
+		}
+		for _, f := range g.World.Flags {
+			if f.Carrier != 0 && f.Carrier == g.You {
+				fmt.Fprintln(txt, "you have the flag, bring it home!")
+			}
+		}
+		if you := g.World.Player(g.You); you != nil && you.Status == protocol.StatusEliminated {
+			fmt.Fprintln(txt, "you are out! wait for the next match")
+		} else if you != nil && you.Life <= 0 {
+			fmt.Fprintf(txt, "you died! respawn in %d\n", int(math.Ceil(you.RespawnTime)))
+		} else if you != nil {
+			if you.Ammo > 0 {
+				fmt.Fprintf(txt, "%s %d\n", you.Weapon, you.Ammo)
+			} else {
+				fmt.Fprintln(txt, you.Weapon)
+			}
+			effects := []struct {
+				name string
+				left float64
+			}{{"rapid fire", you.RapidFire}, {"damage", you.DamageBoost}, {"shield", you.Shield}, {"speed", you.SpeedBoost}}
+			for _, e := range effects {
+				if e.left > 0 {
+					fmt.Fprintf(txt, "%s %d\n", e.name, int(math.Ceil(e.left)))
+				}
+			}
+		}
+	case protocol.StatusScoreboard:
+		players := make([]protocol.Player, len(g.World.Players))
+		copy(players, g.World.Players)
+		sort.Slice(players, func(i, j int) bool {
+			return players[i].Score > players[j].Score
+		})
+		fmt.Fprintf(txt, "next match in %d\n\n", left)
+		for _, t := range g.World.Teams {
+			fmt.Fprintf(txt, "team %-19s %5d\n", t.Name, t.Score)
+		}
+		if len(g.World.Teams) > 0 {
+			fmt.Fprintln(txt)
+		}
+		fmt.Fprintf(txt, "%-24s %5s %3s %3s %3s %6s\n", "", "score", "K", "D", "A", "damage")
+		for _, p := range players {
+			name := p.Name
+			if p.Bot {
+				name += " [bot]"
+			}
+			fmt.Fprintf(txt, "%-24s %5d %3d %3d %3d %6.0f\n", name, p.Score, p.Kills, p.Deaths, p.Assists, p.DamageDealt)
+		}
+	}
+	txt.Draw(win, pixel.IM.Scaled(txt.Orig, 2))
+}
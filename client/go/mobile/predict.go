@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/maxxxlounge/websocket/game"
+	"github.com/maxxxlounge/websocket/protocol"
+)
+
+// MaxPending caps the inputs kept for replay when the server stops
+// acknowledging them.
+const MaxPending int = 120
+
+// Corrections shorter than SmoothDistance are blended in at SmoothRate
+// per second, longer ones, like a respawn, snap the ship.
+const SmoothDistance float64 = 40
+const SmoothRate float64 = 10
+
+// pending is an input the server did not acknowledge yet and how long it
+// was held.
+type pending struct {
+	in protocol.Input
+	dt float64
+}
+
+// Predictor moves the own ship with the same MovePlayer as the server as
+// soon as an input is read, instead of a round trip later. Every snapshot
+// puts the ship back to the server's state and replays the inputs the
+// server did not apply yet on top of it. Walls, asteroids and other ships
+// are left to the server, the next snapshot corrects them.
+type Predictor struct {
+	ship    game.Player
+	bounds  game.Bounds
+	tick    uint64
+	active  bool
+	pending []pending
+	// offset is the part of the last correction not shown yet
+	offset pixel.Vec
+}
+
+func NewPredictor() *Predictor {
+	return &Predictor{ship: game.Player{
+		Acceleration: game.PlayerAcceleration,
+		Drag:         game.PlayerDrag,
+		MaxSpeed:     game.PlayerMaxSpeed,
+	}}
+}
+
+// Reconcile rebases the ship on a new snapshot of f.
+func (pr *Predictor) Reconcile(f *protocol.Frame) {
+	you := f.World.Player(f.You)
+	if you == nil || f.World.Tick == pr.tick {
+		return
+	}
+	pr.tick = f.World.Tick
+	before := pixel.V(pr.ship.X, pr.ship.Y)
+	wasActive := pr.active
+
+	acked := 0
+	for acked < len(pr.pending) && pr.pending[acked].in.Seq <= f.Ack {
+		acked++
+	}
+	pr.pending = pr.pending[acked:]
+	b := f.World.Bounds
+	pr.bounds = game.Bounds{X: b.X, Y: b.Y, Width: b.Width, Height: b.Height, Edge: game.EdgeMode(b.Edge)}
+	pr.ship.X, pr.ship.Y = you.X, you.Y
+	pr.ship.Velocity = pixel.V(you.VX, you.VY)
+	pr.ship.Rotation = game.RotationDegree(you.Rotation)
+	pr.ship.Life = you.Life
+	pr.ship.Effects.Speed = you.SpeedBoost
+
+	// the server doesn't move the dead or anybody on the scoreboard
+	pr.active = you.Life > 0 && f.World.Status != protocol.StatusScoreboard
+	if !pr.active {
+		pr.pending, pr.offset = nil, pixel.ZV
+		return
+	}
+	for _, p := range pr.pending {
+		pr.move(p.in, p.dt)
+	}
+	d := before.Add(pr.offset).Sub(pixel.V(pr.ship.X, pr.ship.Y))
+	if !wasActive || d.Len() > SmoothDistance {
+		d = pixel.ZV
+	}
+	pr.offset = d
+}
+
+// Apply moves the ship by in held for dt seconds and keeps it for the
+// replay until the server acknowledges it.
+func (pr *Predictor) Apply(in protocol.Input, dt float64) {
+	pr.offset = pr.offset.Scaled(math.Max(0, 1-SmoothRate*dt))
+	if !pr.active {
+		return
+	}
+	if n := len(pr.pending); n > 0 && pr.pending[n-1].in.Seq == in.Seq {
+		pr.pending[n-1].dt += dt
+	} else {
+		pr.pending = append(pr.pending, pending{in: in, dt: dt})
+	}
+	if len(pr.pending) > MaxPending {
+		pr.pending = pr.pending[len(pr.pending)-MaxPending:]
+	}
+	pr.move(in, dt)
+}
+
+// Show returns you as the server sent it, moved to the predicted ship for
+// drawing. you is a copy, the decoded frame stays as the server sent it.
+func (pr *Predictor) Show(you protocol.Player) protocol.Player {
+	if !pr.active {
+		return you
+	}
+	you.X, you.Y = pr.ship.X+pr.offset.X, pr.ship.Y+pr.offset.Y
+	you.VX, you.VY = pr.ship.Velocity.X, pr.ship.Velocity.Y
+	you.Rotation = float64(pr.ship.Rotation)
+	return you
+}
+
+// move runs MovePlayer in steps no longer than the server's ticks.
+func (pr *Predictor) move(in protocol.Input, dt float64) {
+	s := &pr.ship
+	s.Left, s.Right, s.Up, s.Down = in.Left, in.Right, in.Up, in.Down
+	s.AxisX, s.AxisY = in.AxisX, in.AxisY
+	for dt > 0 {
+		step := math.Min(dt, 1/float64(tickRate))
+		s.MovePlayer(step, pr.bounds)
+		dt -= step
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/maxxxlounge/websocket/protocol"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// Step is one input of a script, held for Duration seconds.
+type Step struct {
+	Duration float64 `json:"duration"`
+	Left     bool    `json:"left"`
+	Right    bool    `json:"right"`
+	Up       bool    `json:"up"`
+	Down     bool    `json:"down"`
+	Fire     bool    `json:"fire"`
+	AxisX    float64 `json:"axisX"`
+	AxisY    float64 `json:"axisY"`
+	Weapon   string  `json:"weapon"`
+}
+
+// LoadScript reads a JSON list of steps, the clients play it in a loop.
+func LoadScript(path string) ([]Step, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading script")
+	}
+	var steps []Step
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, errors.Wrapf(err, "error parsing script %s", path)
+	}
+	if len(steps) == 0 {
+		return nil, errors.Errorf("script %s has no steps", path)
+	}
+	for i, s := range steps {
+		if s.Duration <= 0 {
+			return nil, errors.Errorf("step %d of %s has duration %v", i, path, s.Duration)
+		}
+	}
+	return steps, nil
+}
+
+// Client is one simulated player. It sends inputs at a fixed rate, acks
+// binary snapshots like the real client and measures what it gets back.
+type Client struct {
+	name   string
+	url    string
+	room   string
+	rate   float64
+	script []Step
+	stats  *Stats
+	rng    *rand.Rand
+
+	conn *websocket.Conn
+	// writes come from the input loop and the acks of the reader
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// sent are the send times of the inputs not acknowledged yet
+	sent map[uint32]time.Time
+}
+
+// Run plays until stop is closed or the server drops the connection.
+func (c *Client) Run(stop <-chan struct{}) {
+	var err error
+	c.conn, _, err = websocket.DefaultDialer.Dial(c.url, nil)
+	if err != nil {
+		log.Errorf("%s: dial: %v", c.name, err)
+		c.stats.Disconnect()
+		return
+	}
+	c.stats.Connected(1)
+	defer c.stats.Connected(-1)
+	c.sent = make(map[uint32]time.Time)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.read()
+	}()
+	// join first, any other message would quick-join a random room
+	c.send(protocol.NewJoinRoom(c.room))
+	c.send(protocol.NewSetup(c.name))
+	c.play(stop, done)
+	c.conn.Close()
+	<-done
+}
+
+// play sends inputs until stop or done is closed. A closed done means the
+// connection dropped.
+func (c *Client) play(stop, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / c.rate))
+	defer ticker.Stop()
+	var seq uint32
+	var step Step
+	var left float64
+	next := 0
+	for {
+		select {
+		case <-stop:
+			return
+		case <-done:
+			c.stats.Disconnect()
+			return
+		case <-ticker.C:
+		}
+		left -= 1 / c.rate
+		if left <= 0 {
+			if c.script != nil {
+				step = c.script[next%len(c.script)]
+				next++
+			} else {
+				step = c.randomStep()
+			}
+			left = step.Duration
+		}
+		seq++
+		in := protocol.NewInput(seq)
+		in.Left, in.Right, in.Up, in.Down, in.Fire = step.Left, step.Right, step.Up, step.Down, step.Fire
+		in.AxisX, in.AxisY, in.Weapon = step.AxisX, step.AxisY, step.Weapon
+		c.mu.Lock()
+		c.sent[seq] = time.Now()
+		c.mu.Unlock()
+		// a failed write also ends the reader, which closes done
+		c.send(in)
+	}
+}
+
+// randomStep steers in one of the eight directions, or not at all, and
+// fires half of the time.
+func (c *Client) randomStep() Step {
+	s := Step{Duration: 0.2 + c.rng.Float64()*1.5, Fire: c.rng.Intn(2) == 0}
+	switch c.rng.Intn(3) {
+	case 0:
+		s.Left = true
+	case 1:
+		s.Right = true
+	}
+	switch c.rng.Intn(3) {
+	case 0:
+		s.Up = true
+	case 1:
+		s.Down = true
+	}
+	return s
+}
+
+func (c *Client) read() {
+	decoder := protocol.NewBinaryDecoder()
+	for {
+		mType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if mType == websocket.BinaryMessage {
+			frame, err := decoder.Decode(data)
+			if err != nil {
+				log.Errorf("%s: error decoding snapshot: %v", c.name, err)
+				c.stats.Error()
+				continue
+			}
+			c.stats.Snapshot(len(data))
+			c.acked(frame.Ack)
+			c.send(protocol.NewAck(frame.Epoch, frame.World.Tick))
+			continue
+		}
+		msg, err := protocol.DecodeServer(data)
+		if err != nil {
+			log.Errorf("%s: error decoding server message: %v", c.name, err)
+			c.stats.Error()
+			continue
+		}
+		switch m := msg.(type) {
+		case *protocol.Frame:
+			c.stats.Snapshot(len(data))
+			c.acked(m.Ack)
+		case *protocol.Error:
+			log.Errorf("%s: server error %s: %s", c.name, m.Code, m.Message)
+			c.stats.Error()
+			c.stats.Received(len(data))
+		default:
+			c.stats.Received(len(data))
+		}
+	}
+}
+
+// acked records the latency of every input up to ack.
+func (c *Client) acked(ack uint32) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for seq, at := range c.sent {
+		if seq <= ack {
+			c.stats.Latency(now.Sub(at))
+			delete(c.sent, seq)
+		}
+	}
+}
+
+func (c *Client) send(msg interface{}) error {
+	data, err := protocol.Encode(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
@@ -0,0 +1,110 @@
+// Command loadbot connects many headless players to a server to load and
+// soak test it. Every client joins a room, sends random or scripted inputs
+// with the real protocol and acks binary snapshots like the game client.
+// It reports the snapshot rate, the traffic, the input latency (time until
+// a snapshot acks the input) and the disconnects.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/maxxxlounge/websocket/protocol"
+
+	logrus "github.com/sirupsen/logrus"
+)
+
+var log = logrus.New()
+
+func main() {
+	addr := flag.String("addr", "localhost:8888", "server address")
+	n := flag.Int("n", 20, "number of clients")
+	room := flag.String("room", "", "id of the room to join, any room with a free slot if empty")
+	encoding := flag.String("encoding", protocol.EncodingBinary, "snapshot encoding: binary or json")
+	rate := flag.Float64("rate", 20, "inputs sent per second by each client")
+	ramp := flag.Duration("ramp", 5*time.Second, "time to connect all clients")
+	duration := flag.Duration("duration", 0, "stop after this long, 0 runs until interrupted")
+	report := flag.Duration("report", 5*time.Second, "time between two reports")
+	scriptFile := flag.String("script", "", "JSON list of input steps to play in a loop instead of random inputs")
+	flag.Parse()
+
+	if *encoding != protocol.EncodingBinary && *encoding != protocol.EncodingJSON {
+		log.Fatalf("unknown encoding %q", *encoding)
+	}
+	if *n < 1 || *rate <= 0 {
+		log.Fatal("need at least one client and a positive input rate")
+	}
+	var script []Step
+	if *scriptFile != "" {
+		var err error
+		script, err = LoadScript(*scriptFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	u := url.URL{Scheme: "ws", Host: *addr, Path: "/connect", RawQuery: "encoding=" + *encoding}
+
+	stats := &Stats{}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	fmt.Printf("connecting %d clients to %s\n", *n, u.String())
+	// the ramp is counted too, so no client is added after Wait
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < *n; i++ {
+			c := &Client{
+				name:   fmt.Sprintf("loadbot %d", i+1),
+				url:    u.String(),
+				room:   *room,
+				rate:   *rate,
+				script: script,
+				stats:  stats,
+				rng:    rand.New(rand.NewSource(int64(i))),
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Run(stop)
+			}()
+			select {
+			case <-stop:
+				return
+			case <-time.After(*ramp / time.Duration(*n)):
+			}
+		}
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	var end <-chan time.Time
+	if *duration > 0 {
+		end = time.After(*duration)
+	}
+	ticker := time.NewTicker(*report)
+	defer ticker.Stop()
+	start := time.Now()
+	last := start
+loop:
+	for {
+		select {
+		case now := <-ticker.C:
+			fmt.Println(stats.Report(now.Sub(last)))
+			last = now
+		case <-end:
+			break loop
+		case <-interrupt:
+			break loop
+		}
+	}
+	close(stop)
+	wg.Wait()
+	stats.Report(time.Since(last))
+	fmt.Println(stats.Summary(time.Since(start)))
+}
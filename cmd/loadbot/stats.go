@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats collects what the clients measure. The counters are reset by
+// every report, the totals are kept for the summary.
+type Stats struct {
+	mu          sync.Mutex
+	connected   int
+	snapshots   int
+	bytes       int
+	latencies   []time.Duration
+	disconnects int
+	errors      int
+
+	totalSnapshots   int
+	totalBytes       int
+	totalLatencies   []time.Duration
+	totalDisconnects int
+	totalErrors      int
+}
+
+func (s *Stats) Connected(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected += delta
+}
+
+// Snapshot counts a snapshot frame of size bytes.
+func (s *Stats) Snapshot(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots++
+	s.bytes += size
+}
+
+// Received counts a message that isn't a snapshot.
+func (s *Stats) Received(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes += size
+}
+
+// Latency records the time from sending an input to the first snapshot
+// acknowledging it.
+func (s *Stats) Latency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, d)
+}
+
+func (s *Stats) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnects++
+}
+
+// Error counts an error message of the server.
+func (s *Stats) Error() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors++
+}
+
+// Report returns a line with the rates since the previous report, which
+// was elapsed ago.
+func (s *Stats) Report(elapsed time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec := elapsed.Seconds()
+	line := fmt.Sprintf("conns %4d  snapshots %7.1f/s  %7.1f/s per conn  in %8.1f KB/s  latency %s  disconnects %d  errors %d",
+		s.connected, float64(s.snapshots)/sec, perConn(float64(s.snapshots)/sec, s.connected),
+		float64(s.bytes)/sec/1024, percentiles(s.latencies), s.disconnects, s.errors)
+
+	s.totalSnapshots += s.snapshots
+	s.totalBytes += s.bytes
+	s.totalLatencies = append(s.totalLatencies, s.latencies...)
+	s.totalDisconnects += s.disconnects
+	s.totalErrors += s.errors
+	s.snapshots, s.bytes, s.latencies, s.disconnects, s.errors = 0, 0, nil, 0, 0
+	return line
+}
+
+// Summary returns the totals of a run that took elapsed.
+func (s *Stats) Summary(elapsed time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec := elapsed.Seconds()
+	return fmt.Sprintf("%v: %d snapshots (%.1f/s), %.1f MB in (%.1f KB/s), latency %s, %d disconnects, %d errors",
+		elapsed.Round(time.Second), s.totalSnapshots, float64(s.totalSnapshots)/sec,
+		float64(s.totalBytes)/1024/1024, float64(s.totalBytes)/sec/1024,
+		percentiles(s.totalLatencies), s.totalDisconnects, s.totalErrors)
+}
+
+func perConn(v float64, conns int) float64 {
+	if conns == 0 {
+		return 0
+	}
+	return v / float64(conns)
+}
+
+// percentiles formats the median, 95th percentile and maximum of l.
+func percentiles(l []time.Duration) string {
+	if len(l) == 0 {
+		return "-"
+	}
+	sorted := make([]time.Duration, len(l))
+	copy(sorted, l)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))].Round(100 * time.Microsecond)
+	}
+	return fmt.Sprintf("p50 %v p95 %v max %v", at(0.5), at(0.95), sorted[len(sorted)-1].Round(100*time.Microsecond))
+}
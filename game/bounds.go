@@ -0,0 +1,84 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/pkg/errors"
+)
+
+// EdgeMode is what happens to ships and bullets at the arena edges.
+type EdgeMode string
+
+// EdgeWall stops ships and removes bullets, EdgeBounce bounces both back
+// and EdgeWrap lets them come out on the opposite side.
+const EdgeWall EdgeMode = "wall"
+const EdgeBounce EdgeMode = "bounce"
+const EdgeWrap EdgeMode = "wrap"
+
+func ParseEdgeMode(s string) (EdgeMode, error) {
+	switch m := EdgeMode(s); m {
+	case EdgeWall, EdgeBounce, EdgeWrap:
+		return m, nil
+	}
+	return "", errors.Errorf("unknown edge mode %q", s)
+}
+
+// Bounds is the arena, from X, Y to X+Width, Y+Height. It starts at the
+// origin unless a mode shrinks it.
+type Bounds struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Edge   EdgeMode
+}
+
+func (b Bounds) Contains(v pixel.Vec) bool {
+	return v.X >= b.X && v.Y >= b.Y && v.X <= b.X+b.Width && v.Y <= b.Y+b.Height
+}
+
+func (b Bounds) Centre() pixel.Vec {
+	return pixel.V(b.X+b.Width/2, b.Y+b.Height/2)
+}
+
+// Keep applies the edge mode to an entity at pos moving with vel, bounced
+// entities keep restitution of their speed. It returns how far pos was
+// moved by wrapping and whether the entity reached an edge.
+func (b Bounds) Keep(pos, vel *pixel.Vec, restitution float64) (pixel.Vec, bool) {
+	sx, hitX := b.keepAxis(&pos.X, &vel.X, b.X, b.Width, restitution)
+	sy, hitY := b.keepAxis(&pos.Y, &vel.Y, b.Y, b.Height, restitution)
+	return pixel.V(sx, sy), hitX || hitY
+}
+
+// keepAxis keeps x between min and min+size.
+func (b Bounds) keepAxis(x, v *float64, min, size, restitution float64) (float64, bool) {
+	l := *x - min
+	if l >= 0 && l <= size {
+		return 0, false
+	}
+	switch b.Edge {
+	case EdgeWrap:
+		l = math.Mod(l, size)
+		if l < 0 {
+			l += size
+		}
+		old := *x
+		*x = min + l
+		return *x - old, true
+	case EdgeBounce:
+		if l < 0 {
+			l = -l
+		} else {
+			l = 2*size - l
+		}
+		// a bounce can't leave the arena on the other side
+		l = math.Max(0, math.Min(size, l))
+		*v = -*v * restitution
+	default:
+		l = math.Max(0, math.Min(size, l))
+		*v = 0
+	}
+	*x = min + l
+	return 0, true
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+func TestKeep(t *testing.T) {
+	tests := []struct {
+		name     string
+		edge     EdgeMode
+		pos, vel pixel.Vec
+		wantPos  pixel.Vec
+		wantVel  pixel.Vec
+		shift    pixel.Vec
+		hit      bool
+	}{
+		{"wall inside", EdgeWall, pixel.V(50, 40), pixel.V(5, -5), pixel.V(50, 40), pixel.V(5, -5), pixel.ZV, false},
+		{"wall on the edge", EdgeWall, pixel.V(110, 20), pixel.V(5, -5), pixel.V(110, 20), pixel.V(5, -5), pixel.ZV, false},
+		{"wall left", EdgeWall, pixel.V(5, 40), pixel.V(-10, 3), pixel.V(10, 40), pixel.V(0, 3), pixel.ZV, true},
+		{"wall corner", EdgeWall, pixel.V(115, 75), pixel.V(10, 10), pixel.V(110, 70), pixel.ZV, pixel.ZV, true},
+		{"bounce inside", EdgeBounce, pixel.V(50, 40), pixel.V(5, -5), pixel.V(50, 40), pixel.V(5, -5), pixel.ZV, false},
+		{"bounce left", EdgeBounce, pixel.V(5, 40), pixel.V(-10, 3), pixel.V(15, 40), pixel.V(5, 3), pixel.ZV, true},
+		{"bounce top", EdgeBounce, pixel.V(50, 75), pixel.V(0, 20), pixel.V(50, 65), pixel.V(0, -10), pixel.ZV, true},
+		{"bounce far out", EdgeBounce, pixel.V(250, 40), pixel.V(300, 0), pixel.V(10, 40), pixel.V(-150, 0), pixel.ZV, true},
+		{"wrap inside", EdgeWrap, pixel.V(50, 40), pixel.V(5, -5), pixel.V(50, 40), pixel.V(5, -5), pixel.ZV, false},
+		{"wrap right", EdgeWrap, pixel.V(115, 40), pixel.V(10, 0), pixel.V(15, 40), pixel.V(10, 0), pixel.V(-100, 0), true},
+		{"wrap left", EdgeWrap, pixel.V(5, 40), pixel.V(-10, 0), pixel.V(105, 40), pixel.V(-10, 0), pixel.V(100, 0), true},
+		{"wrap corner", EdgeWrap, pixel.V(5, 15), pixel.V(-10, -10), pixel.V(105, 65), pixel.V(-10, -10), pixel.V(100, 50), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bounds{X: 10, Y: 20, Width: 100, Height: 50, Edge: tt.edge}
+			pos, vel := tt.pos, tt.vel
+			shift, hit := b.Keep(&pos, &vel, 0.5)
+			if pos != tt.wantPos || vel != tt.wantVel || shift != tt.shift || hit != tt.hit {
+				t.Errorf("got %v moving %v shifted by %v and hit %v, want %v moving %v shifted by %v and hit %v",
+					pos, vel, shift, hit, tt.wantPos, tt.wantVel, tt.shift, tt.hit)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+type ColliderShape string
+
+const ShapeCircle ColliderShape = "circle"
+const ShapeBox ColliderShape = "box"
+
+// Collider is the hitbox of an entity in its local frame: centred on the
+// entity's position and, for boxes, facing up before rotation.
+type Collider struct {
+	Shape  ColliderShape
+	Radius float64
+	HalfW  float64
+	HalfH  float64
+}
+
+// The ship box matches the 12x20 pig sprite.
+var ShipCollider = BoxCollider(12, 20)
+var BulletCollider = CircleCollider(1.5)
+
+func CircleCollider(radius float64) Collider {
+	return Collider{Shape: ShapeCircle, Radius: radius}
+}
+
+func BoxCollider(width, height float64) Collider {
+	return Collider{Shape: ShapeBox, HalfW: width / 2, HalfH: height / 2}
+}
+
+// BoundingRadius is the radius of the smallest circle around the collider.
+func (c Collider) BoundingRadius() float64 {
+	if c.Shape == ShapeBox {
+		return math.Hypot(c.HalfW, c.HalfH)
+	}
+	return c.Radius
+}
+
+// Sweep tests a circle of radius r moving from a to b against the
+// collider placed at pos with rotation rot. It returns the fraction of the
+// way from a to b where they first touch.
+func (c Collider) Sweep(pos pixel.Vec, rot RotationDegree, a, b pixel.Vec, r float64) (float64, bool) {
+	if c.Shape == ShapeBox {
+		// in the box frame the test becomes segment against a box grown by r
+		la := a.Sub(pos).Rotated(-float64(rot))
+		lb := b.Sub(pos).Rotated(-float64(rot))
+		return sweepBox(la, lb, c.HalfW+r, c.HalfH+r)
+	}
+	return sweepCircle(a.Sub(pos), b.Sub(pos), c.Radius+r)
+}
+
+// sweepCircle intersects the segment a-b with a circle of radius r around
+// the origin.
+func sweepCircle(a, b pixel.Vec, r float64) (float64, bool) {
+	if a.Len() <= r {
+		return 0, true
+	}
+	d := b.Sub(a)
+	qa := d.Dot(d)
+	if qa == 0 {
+		return 0, false
+	}
+	qb := 2 * a.Dot(d)
+	qc := a.Dot(a) - r*r
+	disc := qb*qb - 4*qa*qc
+	if disc < 0 {
+		return 0, false
+	}
+	t := (-qb - math.Sqrt(disc)) / (2 * qa)
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// sweepBox intersects the segment a-b with the axis aligned box of half
+// size hw, hh around the origin using the slab method.
+func sweepBox(a, b pixel.Vec, hw, hh float64) (float64, bool) {
+	d := b.Sub(a)
+	tmin, tmax := 0.0, 1.0
+	slabs := [2][3]float64{{a.X, d.X, hw}, {a.Y, d.Y, hh}}
+	for _, s := range slabs {
+		start, dir, half := s[0], s[1], s[2]
+		if dir == 0 {
+			if start < -half || start > half {
+				return 0, false
+			}
+			continue
+		}
+		t1 := (-half - start) / dir
+		t2 := (half - start) / dir
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tmin = math.Max(tmin, t1)
+		tmax = math.Min(tmax, t2)
+		if tmin > tmax {
+			return 0, false
+		}
+	}
+	return tmin, true
+}
+
+// Overlap tests collider a at posA/rotA against b at posB/rotB. If they
+// overlap it returns the unit normal pointing from a to b and the depth
+// along it needed to separate them.
+func Overlap(a Collider, posA pixel.Vec, rotA RotationDegree, b Collider, posB pixel.Vec, rotB RotationDegree) (pixel.Vec, float64, bool) {
+	switch {
+	case a.Shape == ShapeCircle && b.Shape == ShapeCircle:
+		return overlapCircles(posA, a.Radius, posB, b.Radius)
+	case a.Shape == ShapeBox && b.Shape == ShapeBox:
+		return overlapBoxes(a, posA, rotA, b, posB, rotB)
+	case a.Shape == ShapeBox:
+		return overlapBoxCircle(a, posA, rotA, posB, b.Radius)
+	default:
+		n, depth, ok := overlapBoxCircle(b, posB, rotB, posA, a.Radius)
+		return n.Scaled(-1), depth, ok
+	}
+}
+
+func overlapCircles(posA pixel.Vec, ra float64, posB pixel.Vec, rb float64) (pixel.Vec, float64, bool) {
+	d := posB.Sub(posA)
+	dist := d.Len()
+	if dist >= ra+rb {
+		return pixel.ZV, 0, false
+	}
+	if dist == 0 {
+		return pixel.V(1, 0), ra + rb, true
+	}
+	return d.Scaled(1 / dist), ra + rb - dist, true
+}
+
+func overlapBoxCircle(box Collider, posBox pixel.Vec, rot RotationDegree, posCircle pixel.Vec, r float64) (pixel.Vec, float64, bool) {
+	local := posCircle.Sub(posBox).Rotated(-float64(rot))
+	closest := pixel.V(
+		math.Max(-box.HalfW, math.Min(box.HalfW, local.X)),
+		math.Max(-box.HalfH, math.Min(box.HalfH, local.Y)),
+	)
+	d := local.Sub(closest)
+	dist := d.Len()
+	if dist >= r {
+		return pixel.ZV, 0, false
+	}
+	var n pixel.Vec
+	var depth float64
+	if dist > 0 {
+		n, depth = d.Scaled(1/dist), r-dist
+	} else {
+		// the centre is inside the box, push out along the nearest side
+		dx := box.HalfW - math.Abs(local.X)
+		dy := box.HalfH - math.Abs(local.Y)
+		if dx < dy {
+			n, depth = pixel.V(math.Copysign(1, local.X), 0), dx+r
+		} else {
+			n, depth = pixel.V(0, math.Copysign(1, local.Y)), dy+r
+		}
+	}
+	return n.Rotated(float64(rot)), depth, true
+}
+
+// overlapBoxes uses the separating axis theorem on the four box axes.
+func overlapBoxes(a Collider, posA pixel.Vec, rotA RotationDegree, b Collider, posB pixel.Vec, rotB RotationDegree) (pixel.Vec, float64, bool) {
+	axA := pixel.V(1, 0).Rotated(float64(rotA))
+	ayA := pixel.V(0, 1).Rotated(float64(rotA))
+	axB := pixel.V(1, 0).Rotated(float64(rotB))
+	ayB := pixel.V(0, 1).Rotated(float64(rotB))
+	d := posB.Sub(posA)
+
+	best := math.Inf(1)
+	var normal pixel.Vec
+	for _, axis := range []pixel.Vec{axA, ayA, axB, ayB} {
+		extA := a.HalfW*math.Abs(axA.Dot(axis)) + a.HalfH*math.Abs(ayA.Dot(axis))
+		extB := b.HalfW*math.Abs(axB.Dot(axis)) + b.HalfH*math.Abs(ayB.Dot(axis))
+		dist := d.Dot(axis)
+		overlap := extA + extB - math.Abs(dist)
+		if overlap <= 0 {
+			return pixel.ZV, 0, false
+		}
+		if overlap < best {
+			best = overlap
+			normal = axis
+			if dist < 0 {
+				normal = axis.Scaled(-1)
+			}
+		}
+	}
+	return normal, best, true
+}
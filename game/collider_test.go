@@ -0,0 +1,93 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+const epsilon = 1e-9
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < epsilon
+}
+
+func TestSweep(t *testing.T) {
+	tests := []struct {
+		name     string
+		collider Collider
+		pos      pixel.Vec
+		rot      RotationDegree
+		a, b     pixel.Vec
+		r        float64
+		hit      bool
+		at       float64
+	}{
+		// the bullet starts and ends the step well clear of the ship
+		{"fast bullet through a ship", ShipCollider, pixel.V(100, 100), 0,
+			pixel.V(0, 100), pixel.V(200, 100), 1.5, true, (100 - 6 - 1.5) / 200.0},
+		{"fast bullet through a circle", CircleCollider(10), pixel.V(100, 100), 0,
+			pixel.V(100, 0), pixel.V(100, 200), 0, true, 0.45},
+		{"rotated box", BoxCollider(40, 4), pixel.ZV, math.Pi / 2,
+			pixel.V(-10, 15), pixel.V(10, 15), 0, true, 0.4},
+		{"rotated box missed", BoxCollider(40, 4), pixel.ZV, math.Pi / 2,
+			pixel.V(-10, 25), pixel.V(10, 25), 0, false, 0},
+		{"grazing a box", ShipCollider, pixel.ZV, 0,
+			pixel.V(7.51, -50), pixel.V(7.51, 50), 1.5, false, 0},
+		{"touching a box", ShipCollider, pixel.ZV, 0,
+			pixel.V(7.49, -50), pixel.V(7.49, 50), 1.5, true, (50 - 10 - 1.5) / 100.0},
+		{"grazing a circle", CircleCollider(10), pixel.ZV, 0,
+			pixel.V(-20, 10.01), pixel.V(20, 10.01), 0, false, 0},
+		{"stopping short", CircleCollider(10), pixel.ZV, 0,
+			pixel.V(-30, 0), pixel.V(-10.5, 0), 0, false, 0},
+		{"starting inside", CircleCollider(10), pixel.ZV, 0,
+			pixel.V(1, 1), pixel.V(50, 50), 0, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, hit := tt.collider.Sweep(tt.pos, tt.rot, tt.a, tt.b, tt.r)
+			if hit != tt.hit || (hit && !near(at, tt.at)) {
+				t.Errorf("got hit %v at %v, want %v at %v", hit, at, tt.hit, tt.at)
+			}
+		})
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	square := BoxCollider(10, 10)
+	tests := []struct {
+		name   string
+		a      Collider
+		posA   pixel.Vec
+		rotA   RotationDegree
+		b      Collider
+		posB   pixel.Vec
+		rotB   RotationDegree
+		hit    bool
+		normal pixel.Vec
+		depth  float64
+	}{
+		{"boxes side by side", square, pixel.ZV, 0, square, pixel.V(8, 1), 0, true, pixel.V(1, 0), 2},
+		{"box below a box", square, pixel.ZV, 0, square, pixel.V(1, -8), 0, true, pixel.V(0, -1), 2},
+		{"rotated boxes", BoxCollider(20, 4), pixel.ZV, math.Pi / 2, BoxCollider(4, 4), pixel.V(0, 11), 0, true, pixel.V(0, 1), 1},
+		{"boxes apart", square, pixel.ZV, 0, square, pixel.V(10.5, 0), 0, false, pixel.ZV, 0},
+		{"circle on a box", square, pixel.ZV, 0, CircleCollider(3), pixel.V(0, 7), 0, true, pixel.V(0, 1), 1},
+		{"circle on a corner", square, pixel.ZV, 0, CircleCollider(3), pixel.V(7, 7), 0, true,
+			pixel.V(1, 1).Unit(), 3 - math.Sqrt(8)},
+		{"box under a circle", CircleCollider(3), pixel.V(0, 7), 0, square, pixel.ZV, 0, true, pixel.V(0, -1), 1},
+		{"circle on a rotated box", BoxCollider(20, 4), pixel.ZV, math.Pi / 2, CircleCollider(3), pixel.V(4, 0), 0, true, pixel.V(1, 0), 1},
+		{"circle past a corner", square, pixel.ZV, 0, CircleCollider(3), pixel.V(7.2, 7.2), 0, false, pixel.ZV, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, depth, hit := Overlap(tt.a, tt.posA, tt.rotA, tt.b, tt.posB, tt.rotB)
+			if hit != tt.hit {
+				t.Fatalf("got hit %v, want %v", hit, tt.hit)
+			}
+			if hit && (!near(n.X, tt.normal.X) || !near(n.Y, tt.normal.Y) || !near(depth, tt.depth)) {
+				t.Errorf("got normal %v depth %v, want %v depth %v", n, depth, tt.normal, tt.depth)
+			}
+		})
+	}
+}
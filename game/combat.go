@@ -0,0 +1,153 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// ShipRestitution is the bounciness of ship against ship collisions.
+const ShipRestitution float64 = 0.8
+
+// Ships colliding faster than RamSpeed both take RamDamage per unit of
+// speed above it.
+const RamSpeed float64 = 120
+const RamDamage float64 = 0.015
+
+// damage hurts p by amount on behalf of attacker, which may be nil for
+// damage without an owner. The lethal hit credits the kill to attacker,
+// everybody else who hurt p since its last spawn gets an assist.
+func (g *Game) damage(p, attacker *Player, amount float64) {
+	if p.Life <= 0 || p.Invulnerable > 0 || p.Effects.Shield > 0 || amount <= 0 {
+		return
+	}
+	if !g.CanHurt(attacker, p) {
+		return
+	}
+	dealt := math.Min(amount, p.Life)
+	p.Life -= amount
+	g.emit(Event{Type: EventHit, Player: p, Other: attacker, Value: dealt})
+	g.mode.Hit(g, p, attacker, dealt)
+	if attacker != nil && attacker != p {
+		attacker.Hits++
+		attacker.DamageDealt += dealt
+		if p.attackers == nil {
+			p.attackers = make(map[*Player]bool)
+		}
+		p.attackers[attacker] = true
+	}
+	if p.Life <= 0 {
+		g.kill(p, attacker)
+	}
+}
+
+// kill credits the death of p, the mode decides what it scores.
+func (g *Game) kill(p, killer *Player) {
+	p.Deaths++
+	if killer != nil && killer != p && !g.Allies(killer, p) {
+		killer.Kills++
+	}
+	for a := range p.attackers {
+		if a == killer || g.Allies(a, p) {
+			continue
+		}
+		// attackers who left the game in the meantime get nothing
+		if g.playerMap[a.UUID] == a {
+			a.Assists++
+		}
+	}
+	g.die(p, killer)
+	g.mode.Kill(g, p, killer)
+}
+
+// collideShips pushes overlapping ships apart and bounces them off each
+// other. Hard rams hurt both ships, each one credited to the other.
+func (g *Game) collideShips() {
+	for i, a := range g.Players {
+		if a.Life <= 0 {
+			continue
+		}
+		for _, id := range g.nearPath(a.prev, pixel.V(a.X, a.Y), a.Collider.BoundingRadius()) {
+			b := g.Players[id]
+			if id <= i || b.Life <= 0 {
+				continue
+			}
+			n, depth, ok := Overlap(a.Collider, pixel.V(a.X, a.Y), a.Rotation, b.Collider, pixel.V(b.X, b.Y), b.Rotation)
+			if !ok {
+				continue
+			}
+			a.X -= n.X * depth / 2
+			a.Y -= n.Y * depth / 2
+			b.X += n.X * depth / 2
+			b.Y += n.Y * depth / 2
+
+			closing := a.Velocity.Sub(b.Velocity).Dot(n)
+			if closing <= 0 {
+				continue
+			}
+			j := (1 + ShipRestitution) * closing / 2
+			a.Velocity = a.Velocity.Sub(n.Scaled(j))
+			b.Velocity = b.Velocity.Add(n.Scaled(j))
+			// ships bounce off each other in the countdown too, but only hurt
+			// each other while playing
+			if closing > RamSpeed && g.Status == Playing {
+				dmg := (closing - RamSpeed) * RamDamage
+				g.damage(a, b, dmg)
+				g.damage(b, a, dmg)
+			}
+		}
+	}
+}
+
+// collideBullet tests the path of b in this step against the ships and
+// obstacles. Ships behind an obstacle are safe, piercing bullets hurt every
+// ship in front of it.
+func (g *Game) collideBullet(b *Bullet) {
+	pos := pixel.V(b.X, b.Y)
+	owner := g.playerMap[b.Owner]
+	first, wall := g.obstacleHit(b)
+	if !wall {
+		first = math.Inf(1)
+	}
+	var target *Player
+	var pierced []*Player
+	for _, id := range g.nearPath(b.prev, pos, b.Collider.Radius) {
+		p := g.Players[id]
+		if p.Life <= 0 || p.Invulnerable > 0 || p.UUID == b.Owner || b.hit[p] {
+			continue
+		}
+		// without friendly fire bullets fly through teammates
+		if owner != nil && !g.CanHurt(owner, p) {
+			continue
+		}
+		ship := pixel.V(p.X, p.Y)
+		// sweep in the frame of the ship, which moved as well
+		from := b.prev.Add(ship.Sub(p.prev))
+		t, hit := p.Collider.Sweep(ship, p.Rotation, from, pos, b.Collider.Radius)
+		if !hit || t >= first {
+			continue
+		}
+		if b.Pierce {
+			pierced = append(pierced, p)
+			continue
+		}
+		first, target = t, p
+	}
+	for _, p := range pierced {
+		if b.hit == nil {
+			b.hit = make(map[*Player]bool)
+		}
+		b.hit[p] = true
+		g.damage(p, owner, b.Damage)
+	}
+	if target == nil && !wall {
+		return
+	}
+	b.Exhausted = true
+	if target != nil {
+		g.damage(target, owner, b.Damage)
+	}
+	if b.Blast > 0 {
+		g.explode(b, b.prev.Add(pos.Sub(b.prev).Scaled(first)), target)
+	}
+}
@@ -0,0 +1,84 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+
+	guuid "github.com/google/uuid"
+)
+
+// TestKillCredit hurts the first of four players to death and checks who
+// gets the kill and who the assists.
+func TestKillCredit(t *testing.T) {
+	type step func(g *Game, ps []*Player)
+	// hit hurts the victim on behalf of player by, -1 for nobody
+	hit := func(by int, amount float64) step {
+		return func(g *Game, ps []*Player) {
+			var attacker *Player
+			if by >= 0 {
+				attacker = ps[by]
+			}
+			g.damage(ps[0], attacker, amount)
+		}
+	}
+	leave := func(i int) step {
+		return func(g *Game, ps []*Player) { g.DeletePlayer(ps[i].UUID) }
+	}
+	respawn := func(g *Game, ps []*Player) {
+		g.spawn(ps[0])
+		ps[0].Invulnerable = 0
+	}
+	tests := []struct {
+		name    string
+		steps   []step
+		kills   []int
+		assists []int
+	}{
+		{"single attacker", []step{hit(1, 4), hit(1, MaxLife)}, []int{0, 1, 0, 0}, []int{0, 0, 0, 0}},
+		{"lethal hit gets the kill", []step{hit(1, 4), hit(2, 5), hit(3, MaxLife)}, []int{0, 0, 0, 1}, []int{0, 1, 1, 0}},
+		{"one assist per kill", []step{hit(1, 1), hit(1, 1), hit(1, 1), hit(2, MaxLife)}, []int{0, 0, 1, 0}, []int{0, 1, 0, 0}},
+		{"no attacker", []step{hit(1, 4), hit(-1, MaxLife)}, []int{0, 0, 0, 0}, []int{0, 1, 0, 0}},
+		{"self damage", []step{hit(0, 4), hit(0, MaxLife)}, []int{0, 0, 0, 0}, []int{0, 0, 0, 0}},
+		{"attacker left", []step{hit(1, 4), leave(1), hit(2, MaxLife)}, []int{0, 0, 1, 0}, []int{0, 0, 0, 0}},
+		{"spawn forgets the attackers", []step{hit(1, 4), respawn, hit(2, MaxLife)}, []int{0, 0, 1, 0}, []int{0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New(nil)
+			var ps []*Player
+			for i := 0; i < 4; i++ {
+				p := g.NewPlayer(guuid.New())
+				p.Invulnerable = 0
+				ps = append(ps, p)
+			}
+			for _, s := range tt.steps {
+				s(g, ps)
+			}
+			var kills, assists []int
+			for _, p := range ps {
+				kills = append(kills, p.Kills)
+				assists = append(assists, p.Assists)
+			}
+			if ps[0].Deaths != 1 || ps[0].Life > 0 {
+				t.Errorf("victim died %d times and has %v life, want it dead once", ps[0].Deaths, ps[0].Life)
+			}
+			if !reflect.DeepEqual(kills, tt.kills) || !reflect.DeepEqual(assists, tt.assists) {
+				t.Errorf("kills are %v and assists %v, want %v and %v", kills, assists, tt.kills, tt.assists)
+			}
+		})
+	}
+}
+
+func TestDamageStats(t *testing.T) {
+	g := New(nil)
+	p, a := g.NewPlayer(guuid.New()), g.NewPlayer(guuid.New())
+	p.Invulnerable = 0
+	g.damage(p, a, 3)
+	g.damage(p, nil, 2)
+	g.damage(p, p, 1)
+	g.damage(p, a, MaxLife)
+	if want := MaxLife - 6; a.Hits != 2 || a.DamageDealt != 3+want || p.Hits != 0 || p.DamageDealt != 0 {
+		t.Errorf("attacker has %d hits dealing %v, victim %d hits dealing %v, want 2 hits dealing %v by the attacker only",
+			a.Hits, a.DamageDealt, p.Hits, p.DamageDealt, 3+want)
+	}
+}
@@ -0,0 +1,188 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// FlagCollider is what ships have to touch to take or return a flag,
+// BaseRadius how close a carrier has to get to its base to capture.
+var FlagCollider = CircleCollider(10)
+
+const BaseRadius float64 = 32
+
+// FlagReturnTime is how long a dropped flag lies around before it goes
+// back to its base by itself.
+const FlagReturnTime float64 = 15
+
+// CaptureScore is the score of the player who captures a flag, the team
+// gets one point.
+const CaptureScore int = 5
+
+// baseMargin keeps the default bases off the edges of the arena.
+const baseMargin float64 = 80
+
+// Flag is the flag of a team in capture the flag. It sits at Base until
+// an enemy takes it, then it follows its Carrier until the carrier
+// captures it or dies and drops it.
+type Flag struct {
+	NetID   uint32
+	Team    int
+	X       float64
+	Y       float64
+	Base    pixel.Vec
+	Carrier *Player
+	// ReturnTime is the time left until a dropped flag returns
+	ReturnTime float64
+}
+
+// AtBase reports whether the flag is home, only then can its team score.
+func (f *Flag) AtBase() bool {
+	return f.Carrier == nil && f.ReturnTime <= 0
+}
+
+// CaptureTheFlag is played by teams: take the enemy flag from its base
+// and bring it to your own while your flag is there.
+type CaptureTheFlag struct{}
+
+func (m *CaptureTheFlag) Name() string                                     { return ModeCaptureTheFlag }
+func (m *CaptureTheFlag) Teams() int                                       { return 2 }
+func (m *CaptureTheFlag) Hit(g *Game, p, attacker *Player, amount float64) {}
+func (m *CaptureTheFlag) Pickup(g *Game, p *Player, k *Pickup)             {}
+
+func (m *CaptureTheFlag) Start(g *Game) {
+	g.Flags = nil
+	for _, t := range g.Teams {
+		base := g.Base(t.ID)
+		g.Flags = append(g.Flags, &Flag{NetID: g.newNetID(), Team: t.ID, X: base.X, Y: base.Y, Base: base})
+	}
+}
+
+func (m *CaptureTheFlag) Tick(g *Game, dt float64) {
+	for _, f := range g.Flags {
+		if c := f.Carrier; c != nil && (c.Life <= 0 || g.playerMap[c.UUID] != c) {
+			g.dropFlag(f)
+		}
+		if c := f.Carrier; c != nil {
+			f.X, f.Y = c.X, c.Y
+			continue
+		}
+		if f.ReturnTime > 0 {
+			f.ReturnTime -= dt
+			if f.ReturnTime <= 0 {
+				g.returnFlag(f, nil)
+			}
+		}
+	}
+	for _, f := range g.Flags {
+		if f.Carrier != nil {
+			g.captureFlag(f)
+			continue
+		}
+		pos := pixel.V(f.X, f.Y)
+		for _, p := range g.PlayersNear(pos, FlagCollider.Radius) {
+			if _, _, ok := Overlap(p.Collider, pixel.V(p.X, p.Y), p.Rotation, FlagCollider, pos, 0); !ok {
+				continue
+			}
+			if p.Team == f.Team {
+				if !f.AtBase() {
+					g.returnFlag(f, p)
+					break
+				}
+				continue
+			}
+			if p.Team != 0 && g.CarriedFlag(p) == nil {
+				f.Carrier, f.ReturnTime = p, 0
+				g.emit(Event{Type: EventFlagTaken, Player: p, Team: f.Team})
+				break
+			}
+		}
+	}
+}
+
+func (m *CaptureTheFlag) Kill(g *Game, p, killer *Player) {
+	g.scoreKill(p, killer)
+	if f := g.CarriedFlag(p); f != nil {
+		g.dropFlag(f)
+	}
+}
+
+func (m *CaptureTheFlag) Over(g *Game) bool {
+	return g.teamScoreReached()
+}
+
+// CarriedFlag returns the flag p carries or nil.
+func (g *Game) CarriedFlag(p *Player) *Flag {
+	for _, f := range g.Flags {
+		if f.Carrier == p {
+			return f
+		}
+	}
+	return nil
+}
+
+// Flag returns the flag of team or nil.
+func (g *Game) Flag(team int) *Flag {
+	for _, f := range g.Flags {
+		if f.Team == team {
+			return f
+		}
+	}
+	return nil
+}
+
+// Base returns the base of team: the one of the map or, if it has none,
+// a spot on a circle around the centre of the arena, the first team on
+// the left.
+func (g *Game) Base(team int) pixel.Vec {
+	for _, b := range g.Map.Bases {
+		if b.Team == team {
+			return pixel.V(b.X, b.Y)
+		}
+	}
+	n := math.Max(2, float64(len(g.Teams)))
+	centre := pixel.V(g.Map.Width/2, g.Map.Height/2)
+	r := math.Max(0, math.Min(g.Map.Width, g.Map.Height)/2-baseMargin)
+	if len(g.Teams) <= 2 {
+		// two bases go to the far ends of the arena
+		r = math.Max(0, g.Map.Width/2-baseMargin)
+	}
+	a := math.Pi + 2*math.Pi*float64(team-1)/n
+	return centre.Add(pixel.V(math.Cos(a), math.Sin(a)).Scaled(r))
+}
+
+func (g *Game) dropFlag(f *Flag) {
+	c := f.Carrier
+	f.X, f.Y = c.X, c.Y
+	f.Carrier = nil
+	f.ReturnTime = FlagReturnTime
+	g.emit(Event{Type: EventFlagDropped, Player: c, Team: f.Team})
+}
+
+// returnFlag puts f back to its base, by p or by itself if p is nil.
+func (g *Game) returnFlag(f *Flag, p *Player) {
+	f.Carrier, f.ReturnTime = nil, 0
+	f.X, f.Y = f.Base.X, f.Base.Y
+	g.emit(Event{Type: EventFlagReturned, Player: p, Team: f.Team})
+}
+
+// captureFlag scores f if its carrier reached the base of its own team
+// while the team's flag is home.
+func (g *Game) captureFlag(f *Flag) {
+	c := f.Carrier
+	own := g.Flag(c.Team)
+	if own == nil || !own.AtBase() {
+		return
+	}
+	if pixel.V(c.X, c.Y).To(own.Base).Len() > BaseRadius {
+		return
+	}
+	c.Score += CaptureScore
+	if t := g.Team(c.Team); t != nil {
+		t.Score++
+	}
+	g.emit(Event{Type: EventFlagCaptured, Player: c, Team: f.Team})
+	f.Carrier = nil
+	f.X, f.Y = f.Base.X, f.Base.Y
+}
@@ -0,0 +1,131 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+	guuid "github.com/google/uuid"
+)
+
+// ctfGame starts a capture the flag match of red and mate against blue,
+// every ship parked away from the bases.
+func ctfGame(t *testing.T) (g *Game, red, mate, blue *Player) {
+	g = New(nil)
+	cfg := DefaultMatchConfig()
+	cfg.Mode = ModeCaptureTheFlag
+	cfg.TimeLimit = 0
+	g.SetMatchConfig(cfg)
+	red = g.NewPlayer(guuid.New())
+	blue = g.NewPlayer(guuid.New())
+	mate = g.NewPlayer(guuid.New())
+	if red.Team != mate.Team || red.Team == blue.Team {
+		t.Fatalf("teams are %d, %d and %d", red.Team, mate.Team, blue.Team)
+	}
+	dt := 1 / float64(g.TickRate())
+	for g.Status != Playing {
+		g.Step(dt)
+	}
+	c := g.Bounds.Centre()
+	park(red, c.X, 100)
+	park(mate, c.X, 300)
+	park(blue, c.X, 600)
+	return g, red, mate, blue
+}
+
+func park(p *Player, x, y float64) {
+	p.X, p.Y, p.Velocity, p.Invulnerable = x, y, pixel.ZV, 0
+	p.prev = pixel.V(x, y)
+}
+
+func dropAt(f *Flag, x, y float64) {
+	f.X, f.Y, f.ReturnTime = x, y, FlagReturnTime
+}
+
+func TestCaptureTheFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(g *Game, red, mate, blue *Player)
+		seconds float64
+		check   func(t *testing.T, g *Game, red, mate, blue *Player)
+	}{
+		{"capture with the own flag home", func(g *Game, red, mate, blue *Player) {
+			g.Flag(blue.Team).Carrier = red
+			base := g.Flag(red.Team).Base
+			park(red, base.X, base.Y)
+		}, 0, func(t *testing.T, g *Game, red, mate, blue *Player) {
+			f := g.Flag(blue.Team)
+			if f.Carrier != nil || !f.AtBase() || f.X != f.Base.X || f.Y != f.Base.Y {
+				t.Errorf("captured flag is at %v, %v carried by %v", f.X, f.Y, f.Carrier)
+			}
+			if red.Score != CaptureScore || g.Team(red.Team).Score != 1 {
+				t.Errorf("carrier has %d points and the team %d", red.Score, g.Team(red.Team).Score)
+			}
+		}},
+		{"no capture while the own flag is away", func(g *Game, red, mate, blue *Player) {
+			g.Flag(blue.Team).Carrier = red
+			own := g.Flag(red.Team)
+			dropAt(own, 500, 200)
+			park(red, own.Base.X, own.Base.Y)
+		}, 0, func(t *testing.T, g *Game, red, mate, blue *Player) {
+			if f := g.Flag(blue.Team); f.Carrier != red {
+				t.Errorf("flag is carried by %v, want the red carrier still", f.Carrier)
+			}
+			if red.Score != 0 || g.Team(red.Team).Score != 0 {
+				t.Errorf("carrier has %d points and the team %d", red.Score, g.Team(red.Team).Score)
+			}
+		}},
+		{"teammate returns the flag", func(g *Game, red, mate, blue *Player) {
+			dropAt(g.Flag(red.Team), 500, 200)
+			park(mate, 500, 200)
+		}, 0, func(t *testing.T, g *Game, red, mate, blue *Player) {
+			f := g.Flag(red.Team)
+			if !f.AtBase() || f.X != f.Base.X || f.Y != f.Base.Y {
+				t.Errorf("flag is at %v, %v, want it back at its base", f.X, f.Y)
+			}
+			returned := false
+			for _, e := range g.TakeEvents() {
+				returned = returned || e.Type == EventFlagReturned && e.Player == mate
+			}
+			if !returned {
+				t.Error("no return by the teammate")
+			}
+		}},
+		{"dropped flag waits", func(g *Game, red, mate, blue *Player) {
+			dropAt(g.Flag(blue.Team), 500, 200)
+		}, FlagReturnTime - 1, func(t *testing.T, g *Game, red, mate, blue *Player) {
+			if f := g.Flag(blue.Team); f.AtBase() || f.X != 500 || f.Y != 200 {
+				t.Errorf("flag is at %v, %v, want it still where it was dropped", f.X, f.Y)
+			}
+		}},
+		{"dropped flag returns by itself", func(g *Game, red, mate, blue *Player) {
+			dropAt(g.Flag(blue.Team), 500, 200)
+		}, FlagReturnTime + 1, func(t *testing.T, g *Game, red, mate, blue *Player) {
+			if f := g.Flag(blue.Team); !f.AtBase() || f.X != f.Base.X || f.Y != f.Base.Y {
+				t.Errorf("flag is at %v, %v, want it back at its base", f.X, f.Y)
+			}
+		}},
+		{"killed carrier drops the flag", func(g *Game, red, mate, blue *Player) {
+			g.Flag(blue.Team).Carrier = red
+			park(red, 400, 200)
+			g.damage(red, blue, 2*MaxLife)
+		}, 0, func(t *testing.T, g *Game, red, mate, blue *Player) {
+			f := g.Flag(blue.Team)
+			if f.Carrier != nil || f.X != 400 || f.Y != 200 || f.ReturnTime < FlagReturnTime-1 {
+				t.Errorf("flag is at %v, %v carried by %v, want it dropped where the carrier died", f.X, f.Y, f.Carrier)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, red, mate, blue := ctfGame(t)
+			tt.setup(g, red, mate, blue)
+			g.TakeEvents()
+			dt := 1 / float64(g.TickRate())
+			g.Step(dt)
+			for s := dt; s < tt.seconds; s += dt {
+				g.Step(dt)
+			}
+			tt.check(t, g, red, mate, blue)
+		})
+	}
+}
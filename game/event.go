@@ -0,0 +1,41 @@
+package game
+
+type EventType string
+
+const EventJoin EventType = "join"
+const EventLeave EventType = "leave"
+const EventHit EventType = "hit"
+const EventStatus EventType = "status"
+const EventDied EventType = "died"
+const EventSpawn EventType = "spawn"
+const EventPickup EventType = "pickup"
+const EventFlagTaken EventType = "flag_taken"
+const EventFlagDropped EventType = "flag_dropped"
+const EventFlagReturned EventType = "flag_returned"
+const EventFlagCaptured EventType = "flag_captured"
+
+// Event is something that happened during a step which clients may want
+// to show, e.g. a hit flash. Player is the subject of the event, Other the
+// player that caused it, if any. Status events carry the new game status
+// and its duration, pickup events the kind of pickup and the life or
+// seconds it gave, flag events the team of the flag.
+type Event struct {
+	Type   EventType
+	Player *Player
+	Other  *Player
+	Value  float64
+	Status GameStatus
+	Pickup PickupKind
+	Team   int
+}
+
+func (g *Game) emit(e Event) {
+	g.events = append(g.events, e)
+}
+
+// TakeEvents returns the events since the last call and clears them.
+func (g *Game) TakeEvents() []Event {
+	e := g.events
+	g.events = nil
+	return e
+}
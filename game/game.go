@@ -2,8 +2,6 @@ package game
 
 import (
 	"math"
-	"math/rand"
-	"sync"
 
 	"github.com/faiface/pixel"
 
@@ -13,31 +11,66 @@ import (
 type Player struct {
 	ID                          string
 	UUID                        guuid.UUID
+	NetID                       uint32
 	Name                        string
 	X                           float64
 	Y                           float64
 	Left, Right, Up, Down, Fire bool
+	AxisX, AxisY                float64
+	SelectWeapon                string
+	InputSeq                    uint32
 	Acceleration                float64
-	Velocity                    float64
+	Drag                        float64
+	MaxSpeed                    float64
+	Velocity                    pixel.Vec
 	Rotation                    RotationDegree
 	Life                        float64
 	Power                       float64
 	ReloadTime                  float64
 	Status                      PlayerStatus
 	Score                       int
-	You                         bool
+	Hits                        int
+	DamageDealt                 float64
+	Kills                       int
+	Deaths                      int
+	Assists                     int
+	RespawnTime                 float64
+	Invulnerable                float64
+	Effects                     Effects
+	Inventory                   []Slot
+	Slot                        int
+	Collider                    Collider
+	// Team is the id of the player's team, 0 outside team matches
+	Team int
+	// Bot is set for players driven by the server instead of a client
+	Bot bool
+	// Paused players get no snapshots, the game goes on for them
+	Paused bool
+	// prev is the position before the current step
+	prev pixel.Vec
+	// attackers are the players who hurt this one since its last spawn
+	attackers map[*Player]bool
+}
+
+// Input is the complete control state of a player at one point in time.
+type Input struct {
+	Seq                         uint32
+	Left, Right, Up, Down, Fire bool
+	AxisX, AxisY                float64
+	Weapon                      string
 }
 
 type PlayerStatus string
 
 const WaitForPlay PlayerStatus = "WaitForPlay"
-const Pause PlayerStatus = "Pause"
-const Resume PlayerStatus = "Resume"
 const Ready PlayerStatus = "Ready"
 const Died PlayerStatus = "Died"
 const Idle PlayerStatus = "Idle"
 const Respawn PlayerStatus = "Respawn"
 
+// Eliminated players are out until the next match, they don't respawn.
+const Eliminated PlayerStatus = "Eliminated"
+
 type Camera struct {
 	Pos       pixel.Vec
 	Speed     float64
@@ -52,35 +85,85 @@ const Playing GameStatus = "Playing"
 const Scoreboard GameStatus = "Scoreboard"
 
 type Game struct {
-	playerMap map[guuid.UUID]*Player
-	Players   []*Player
-	Bullets   []*Bullet
-	Status    GameStatus
-	You       *Player
-	Bounds    Bounds
-}
-type Bounds struct {
-	Width  float64
-	Height float64
+	playerMap   map[guuid.UUID]*Player
+	Players     []*Player
+	Bullets     []*Bullet
+	Pickups     []*Pickup
+	Teams       []*Team
+	Flags       []*Flag
+	Zone        *Zone
+	Status      GameStatus
+	StatusTime  float64
+	Bounds      Bounds
+	Tick        uint64
+	tickRate    int
+	accumulator float64
+	events      []Event
+	lastNetID   uint32
+	match       MatchConfig
+	mode        GameMode
+	weapons     *WeaponRegistry
+	Map         *Map
+	Obstacles   []Obstacle
+	playerIndex *SpatialHash
+	bulletIndex *SpatialHash
+	candidates  []int
+	spawners    []spawner
+	pickupTimer float64
+
+	obstacleIndex *SpatialHash
 }
 
 type Bullet struct {
 	ID        guuid.UUID
+	NetID     uint32
 	X         float64
 	Y         float64
 	active    bool
 	Owner     guuid.UUID
 	Rotation  RotationDegree
+	Velocity  pixel.Vec
 	Damage    float64
 	Speed     float64
+	Lifetime  float64
 	Exhausted bool
+	Collider  Collider
+	Weapon    string
+	Pierce    bool
+	Blast     float64
+	// Range is the distance the bullet flies at most, 0 is unlimited.
+	Range      float64
+	TurnRate   float64
+	SeekRadius float64
+	travelled  float64
+	target     *Player
+	prev       pixel.Vec
+	// hit are the ships a piercing bullet went through already
+	hit map[*Player]bool
 }
 
 type RotationDegree float64
 
+// GameWidth and GameHeight are the size of the default arena.
 const GameWidth float64 = 1024
 const GameHeight float64 = 768
 
+// DefaultTickRate is the number of fixed simulation steps per second.
+const DefaultTickRate int = 60
+
+// maxFrameTime caps the wall time fed into Update so a stalled server
+// catches up with a bounded number of steps instead of spiralling.
+const maxFrameTime float64 = 0.25
+
+// Speeds are in world units per second, times in seconds.
+const PlayerAcceleration float64 = 600
+const PlayerMaxSpeed float64 = 180
+const PlayerDrag float64 = 2.5
+const BulletSpeed float64 = 200
+const BulletLifetime float64 = 5
+const ReloadDelay float64 = 0.25
+const SpawnReloadDelay float64 = 0.5
+
 const RotationUp RotationDegree = math.Pi * 2
 const RotationDown RotationDegree = math.Pi
 const RotationLeft RotationDegree = math.Pi / 2
@@ -91,113 +174,224 @@ const RotationRightDown RotationDegree = (3 * math.Pi / 2) - (math.Pi / 4)
 const RotationLeftUp RotationDegree = (math.Pi / 2) - (math.Pi / 4)
 const RotationLeftDown RotationDegree = (math.Pi / 2) + (math.Pi / 4)
 
-func New() *Game {
+// New creates a game in the arena m, nil is the default map.
+func New(m *Map) *Game {
+	if m == nil {
+		m = DefaultMap()
+	}
 	g := Game{
-		playerMap: make(map[guuid.UUID]*Player),
+		playerMap:     make(map[guuid.UUID]*Player),
+		tickRate:      DefaultTickRate,
+		Status:        WaitForPlayer,
+		match:         DefaultMatchConfig(),
+		mode:          &Deathmatch{},
+		weapons:       DefaultWeapons(),
+		Map:           m,
+		Obstacles:     m.Obstacles(),
+		playerIndex:   NewSpatialHash(SpatialCellSize),
+		bulletIndex:   NewSpatialHash(SpatialCellSize),
+		obstacleIndex: NewSpatialHash(SpatialCellSize),
 		Bounds: Bounds{
-			Width:  GameWidth,
-			Height: GameHeight,
+			Width:  m.Width,
+			Height: m.Height,
+			Edge:   EdgeWall,
 		},
 	}
+	g.indexObstacles()
+	g.resetPickups()
 	return &g
 }
 
-func (p *Player) MovePlayer(dt float64) {
-	if p.Life <= 0 {
-		return
+// newNetID hands out the compact ids used on the wire. They are unique
+// within a game for the lifetime of the server.
+func (g *Game) newNetID() uint32 {
+	g.lastNetID++
+	return g.lastNetID
+}
+
+func (g *Game) SetTickRate(ticksPerSecond int) {
+	if ticksPerSecond <= 0 {
+		ticksPerSecond = DefaultTickRate
 	}
-	if p.ReloadTime > 0 {
-		p.ReloadTime--
+	g.tickRate = ticksPerSecond
+}
+
+func (g *Game) TickRate() int {
+	return g.tickRate
+}
+
+// Update advances the simulation by the wall time elapsed since the last
+// call, running as many fixed steps as fit into it. The remainder is kept
+// for the next call. It returns the number of steps run.
+func (g *Game) Update(elapsed float64) int {
+	if elapsed > maxFrameTime {
+		elapsed = maxFrameTime
+	}
+	g.accumulator += elapsed
+	dt := 1 / float64(g.tickRate)
+	steps := 0
+	for g.accumulator >= dt {
+		g.Step(dt)
+		g.accumulator -= dt
+		steps++
 	}
+	return steps
+}
 
-	if p.Right && p.X < GameWidth {
-		p.X += p.Acceleration * p.Velocity
-		if !p.Up && !p.Down {
-			p.Rotation = RotationRight
-		}
+// Step runs exactly one simulation step of dt seconds.
+func (g *Game) Step(dt float64) {
+	g.updateMatch(dt)
+	if g.Status == Scoreboard {
+		g.Tick++
+		return
 	}
-	if p.Right && p.Up {
-		p.Rotation = RotationRightUp
+	g.updateRespawns(dt)
+	g.updatePickups(dt)
+	g.MovePlayers(dt)
+	g.MoveBullets(dt)
+	g.Collision()
+	g.collectPickups()
+	if g.Status == Playing {
+		g.mode.Tick(g, dt)
 	}
-	if p.Right && p.Down {
-		p.Rotation = RotationRightDown
+	g.Tick++
+}
+
+// Vec returns the unit vector the rotation points at. Rotation 0 (and
+// RotationUp) faces up and angles grow counter-clockwise, as pixel draws them.
+func (r RotationDegree) Vec() pixel.Vec {
+	return pixel.V(-math.Sin(float64(r)), math.Cos(float64(r)))
+}
+
+// RotationFromVec is the inverse of Vec. Straight up maps to RotationUp.
+func RotationFromVec(v pixel.Vec) RotationDegree {
+	a := math.Atan2(-v.X, v.Y)
+	if a <= 0 {
+		a += 2 * math.Pi
 	}
+	return RotationDegree(a)
+}
 
-	if p.Left && p.X > 0 {
-		p.X -= p.Acceleration * p.Velocity
-		if !p.Up && !p.Down {
-			p.Rotation = RotationLeft
+// SetInput replaces the player's controls. Inputs older than the last
+// applied one are ignored; it reports whether in was applied. Seq 0, as
+// bots send, is always applied and leaves the last seq as it is.
+func (p *Player) SetInput(in Input) bool {
+	if in.Seq != 0 {
+		if in.Seq <= p.InputSeq {
+			return false
 		}
+		p.InputSeq = in.Seq
+	}
+	p.Left, p.Right, p.Up, p.Down, p.Fire = in.Left, in.Right, in.Up, in.Down, in.Fire
+	p.AxisX, p.AxisY = in.AxisX, in.AxisY
+	// only a change switches weapons, so a pickup can select its weapon
+	if in.Weapon != p.SelectWeapon {
+		p.SelectWeapon = in.Weapon
+		p.selectWeapon(in.Weapon)
+	}
+	return true
+}
+
+// Thrust combines the digital and analog inputs into a direction with a
+// length of at most 1.
+func (p *Player) Thrust() pixel.Vec {
+	v := pixel.V(p.AxisX, p.AxisY)
+	if p.Left {
+		v.X--
 	}
-	if p.Left && p.Up {
-		p.Rotation = RotationLeftUp
+	if p.Right {
+		v.X++
 	}
-	if p.Left && p.Down {
-		p.Rotation = RotationLeftDown
+	if p.Up {
+		v.Y++
 	}
+	if p.Down {
+		v.Y--
+	}
+	if v.Len() > 1 {
+		v = v.Unit()
+	}
+	return v
+}
 
-	if p.Up && p.Y < GameHeight {
-		p.Y += p.Acceleration * p.Velocity
-		if !p.Left && !p.Right {
-			p.Rotation = RotationUp
-		}
+// MovePlayer integrates the controls of p over dt and keeps it inside
+// bounds.
+func (p *Player) MovePlayer(dt float64, bounds Bounds) {
+	if p.Life <= 0 {
+		return
 	}
-	if p.Down && p.Y > 0 {
-		p.Y -= p.Acceleration * p.Velocity
-		if !p.Left && !p.Right {
-			p.Rotation = RotationDown
-		}
+	if p.ReloadTime > 0 {
+		p.ReloadTime -= dt
 	}
 
+	acceleration, maxSpeed := p.Acceleration, p.MaxSpeed
+	if p.Effects.Speed > 0 {
+		acceleration *= SpeedBoostFactor
+		maxSpeed *= SpeedBoostFactor
+	}
+	thrust := p.Thrust()
+	p.Velocity = p.Velocity.Add(thrust.Scaled(acceleration * dt))
+	p.Velocity = p.Velocity.Scaled(math.Max(0, 1-p.Drag*dt))
+	if speed := p.Velocity.Len(); speed > maxSpeed {
+		p.Velocity = p.Velocity.Scaled(maxSpeed / speed)
+	}
+	if thrust != pixel.ZV {
+		p.Rotation = RotationFromVec(thrust)
+	}
+
+	p.X += p.Velocity.X * dt
+	p.Y += p.Velocity.Y * dt
+	pos := pixel.V(p.X, p.Y)
+	shift, _ := bounds.Keep(&pos, &p.Velocity, ShipRestitution)
+	p.X, p.Y = pos.X, pos.Y
+	// a wrapped ship did not cross the arena, move its start along
+	p.prev = p.prev.Add(shift)
 }
 
 func (g *Game) MovePlayers(dt float64) {
 	for _, v := range g.Players {
-		m := sync.Mutex{}
-		m.Lock()
-		v.MovePlayer(dt)
-		if v.Fire && v.ReloadTime <= 0 {
-			g.AddBullet(v.X, v.Y, v.UUID, v.Rotation, v.Power)
-			v.ReloadTime = 25
+		v.prev = pixel.V(v.X, v.Y)
+		if v.Life <= 0 {
+			continue
+		}
+		v.MovePlayer(dt, g.Bounds)
+		g.collideObstacles(v)
+		if v.Fire && v.ReloadTime <= 0 && g.Status == Playing {
+			g.fire(v)
 		}
-		m.Unlock()
 	}
 }
 
+// Collision resolves contacts between ships and then tests the path every
+// bullet took during the step against the ships, so fast bullets can't
+// pass through. A bullet hits the first ship on its path.
 func (g *Game) Collision() {
-	tolerance := 5.0
-	for k, p := range g.playerMap {
-		if p.Life <= 0 {
+	g.indexPlayers()
+	g.collideShips()
+	// ships were pushed apart, index the final positions for the bullets
+	g.indexPlayers()
+	for _, b := range g.Bullets {
+		if b.Exhausted {
 			continue
 		}
-		for _, b := range g.Bullets {
-			if b.Owner == k {
-				continue
-			}
-			if math.Abs(b.X-p.X) > tolerance {
-				continue
-			}
-			if math.Abs(b.Y-p.Y) > tolerance*2 {
-				continue
-			}
-			p.Life -= 1
-			b.Exhausted = true
-			g.playerMap[b.Owner].Score++
-		}
+		g.collideBullet(b)
 	}
 	for i := len(g.Bullets) - 1; i >= 0; i-- {
 		if g.Bullets[i].Exhausted {
 			g.Bullets = append(g.Bullets[:i], g.Bullets[i+1:]...)
 			continue
 		}
-		if g.Bullets[i].Y > GameHeight || g.Bullets[i].X > GameWidth || g.Bullets[i].X < 0 || g.Bullets[i].Y < 0 {
+		if b := g.Bullets[i]; b.Lifetime <= 0 || (b.Range > 0 && b.travelled >= b.Range) {
+			b.Exhausted = true
+			if b.Blast > 0 {
+				g.explode(b, pixel.V(b.X, b.Y), nil)
+			}
+		}
+		if !g.Bounds.Contains(pixel.V(g.Bullets[i].X, g.Bullets[i].Y)) {
 			g.Bullets[i].Exhausted = true
 		}
 	}
-}
-
-func (g *Game) SetYou(id guuid.UUID) {
-	g.You = g.playerMap[id]
+	g.indexBullets()
 }
 
 func (g *Game) DeletePlayer(id guuid.UUID) {
@@ -212,6 +406,11 @@ func (g *Game) DeletePlayer(id guuid.UUID) {
 		g.Players[len(g.Players)-1] = nil
 		g.Players = g.Players[:len(g.Players)-1]
 	}
+	// the index refers to players by position, which just changed
+	g.indexPlayers()
+	if p, ok := g.playerMap[id]; ok {
+		g.emit(Event{Type: EventLeave, Player: p})
+	}
 	delete(g.playerMap, id)
 }
 
@@ -222,72 +421,83 @@ func (g *Game) GetPlayer(connID guuid.UUID) *Player {
 func (g *Game) NewPlayer(id guuid.UUID) *Player {
 	p := &Player{
 		UUID:         id,
-		X:            rand.Float64() * GameWidth,
-		Y:            rand.Float64() * GameHeight,
+		NetID:        g.newNetID(),
 		Left:         false,
 		Right:        false,
 		Up:           false,
 		Down:         false,
-		Acceleration: 1.5,
-		Velocity:     1,
+		Acceleration: PlayerAcceleration,
+		Drag:         PlayerDrag,
+		MaxSpeed:     PlayerMaxSpeed,
 		Rotation:     math.Pi / 2,
-		Life:         10,
 		Power:        1,
-		ReloadTime:   50.0,
 		Status:       WaitForPlay,
+		Collider:     ShipCollider,
 		Score:        0,
 	}
+	g.assignTeam(p)
+	g.spawn(p)
 	g.playerMap[id] = p
 	g.Players = append(g.Players, p)
+	g.emit(Event{Type: EventJoin, Player: p})
 	return p
 }
 
-func (g *Game) AddBullet(x, y float64, owner guuid.UUID, rotation RotationDegree, damage float64) {
-	bulletID := guuid.New()
-	g.Bullets = append(g.Bullets, &Bullet{
-		ID:        bulletID,
-		X:         x,
-		Y:         y,
-		Owner:     owner,
-		Damage:    damage,
-		Rotation:  rotation,
-		Speed:     2,
-		Exhausted: false,
-	})
+// AddBullet fires a projectile of weapon w along rotation, with w's
+// speed, size and range and the given damage. It inherits the shooter's
+// velocity so shots fired on the move keep up with the ship, except for
+// mines, which stay where they were dropped.
+func (g *Game) AddBullet(x, y float64, owner guuid.UUID, rotation RotationDegree, w *Weapon, damage float64, inherit pixel.Vec) *Bullet {
+	collider := BulletCollider
+	if w.Radius > 0 {
+		collider = CircleCollider(w.Radius)
+	}
+	if w.Speed == 0 {
+		inherit = pixel.ZV
+	}
+	b := &Bullet{
+		ID:         guuid.New(),
+		NetID:      g.newNetID(),
+		X:          x,
+		Y:          y,
+		Owner:      owner,
+		Damage:     damage,
+		Rotation:   rotation,
+		Velocity:   rotation.Vec().Scaled(w.Speed).Add(inherit),
+		Speed:      w.Speed,
+		Lifetime:   w.Lifetime,
+		Exhausted:  false,
+		Collider:   collider,
+		Weapon:     w.Name,
+		Pierce:     w.Pierce,
+		Blast:      w.Blast,
+		Range:      w.Range,
+		TurnRate:   w.TurnRate,
+		SeekRadius: w.SeekRadius,
+		prev:       pixel.V(x, y),
+	}
+	g.Bullets = append(g.Bullets, b)
+	return b
 }
 
-func (g *Game) MoveBullets() {
+func (g *Game) MoveBullets(dt float64) {
 	for _, b := range g.Bullets {
-		switch b.Rotation {
-		case RotationUp:
-			b.Y += b.Speed
-			break
-		case RotationDown:
-			b.Y -= b.Speed
-			break
-		case RotationLeft:
-			b.X -= b.Speed
-			break
-		case RotationRight:
-			b.X += b.Speed
-			break
-		case RotationLeftDown:
-			b.X -= b.Speed
-			b.Y -= b.Speed
-			break
-		case RotationLeftUp:
-			b.X -= b.Speed
-			b.Y += b.Speed
-			break
-		case RotationRightDown:
-			b.X += b.Speed
-			b.Y -= b.Speed
-			break
-		case RotationRightUp:
-			b.X += b.Speed
-			b.Y += b.Speed
-			break
+		b.prev = pixel.V(b.X, b.Y)
+		b.Lifetime -= dt
+		g.steer(b, dt)
+		b.travelled += b.Velocity.Len() * dt
+		b.X += b.Velocity.X * dt
+		b.Y += b.Velocity.Y * dt
+		if g.Bounds.Edge != EdgeBounce && g.Bounds.Edge != EdgeWrap {
+			// bullets leaving through a wall are removed after the hit tests
+			continue
+		}
+		pos := pixel.V(b.X, b.Y)
+		shift, hit := g.Bounds.Keep(&pos, &b.Velocity, 1)
+		b.X, b.Y = pos.X, pos.Y
+		b.prev = b.prev.Add(shift)
+		if hit {
+			b.Rotation = RotationFromVec(b.Velocity)
 		}
 	}
-	return
 }
@@ -0,0 +1,46 @@
+package game
+
+import (
+	"testing"
+
+	guuid "github.com/google/uuid"
+)
+
+func TestSetInputOrder(t *testing.T) {
+	var p Player
+	steps := []struct {
+		in      Input
+		applied bool
+		seq     uint32
+	}{
+		{Input{Seq: 5, Left: true}, true, 5},
+		{Input{Seq: 4, Right: true}, false, 5},
+		{Input{Seq: 0, Up: true}, true, 5},
+		{Input{Seq: 3, Down: true}, false, 5},
+		{Input{Seq: 6, Fire: true}, true, 6},
+	}
+	for i, s := range steps {
+		if applied := p.SetInput(s.in); applied != s.applied || p.InputSeq != s.seq {
+			t.Errorf("input %d: applied %v with seq %d, want %v with seq %d", i, applied, p.InputSeq, s.applied, s.seq)
+		}
+	}
+	if !p.Fire || p.Left || p.Right || p.Up || p.Down {
+		t.Errorf("controls are %+v, want only fire", p)
+	}
+}
+
+func TestSelectEmptiedWeapon(t *testing.T) {
+	g := New(nil)
+	p := g.NewPlayer(guuid.New())
+	g.giveWeapon(p, g.weapons.Get("spread"))
+	p.SetInput(Input{Weapon: "spread"})
+	for p.Weapon().Name == "spread" {
+		g.fire(p)
+	}
+	g.giveWeapon(p, g.weapons.Get("spread"))
+	g.giveWeapon(p, g.weapons.Get("railgun"))
+	p.SetInput(Input{Weapon: "spread"})
+	if w := p.Weapon().Name; w != "spread" {
+		t.Errorf("selected %s, want spread", w)
+	}
+}
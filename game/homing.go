@@ -0,0 +1,56 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// steer turns a homing bullet towards its target by at most its turn rate.
+// Bullets lock on to the closest enemy ahead of them within their seek
+// radius and keep it until it dies or gets out of reach.
+func (g *Game) steer(b *Bullet, dt float64) {
+	if b.TurnRate <= 0 || b.Velocity == pixel.ZV {
+		return
+	}
+	pos := pixel.V(b.X, b.Y)
+	if t := b.target; t != nil && (t.Life <= 0 || g.playerMap[t.UUID] != t || pos.To(pixel.V(t.X, t.Y)).Len() > b.SeekRadius*1.5) {
+		b.target = nil
+	}
+	if b.target == nil {
+		b.target = g.seek(b)
+	}
+	if b.target == nil {
+		return
+	}
+	heading := b.Velocity.Angle()
+	want := pos.To(pixel.V(b.target.X, b.target.Y)).Angle()
+	// shortest way round, in -π..π
+	turn := math.Remainder(want-heading, 2*math.Pi)
+	max := b.TurnRate * dt
+	turn = math.Max(-max, math.Min(max, turn))
+	b.Velocity = b.Velocity.Rotated(turn)
+	b.Rotation = RotationFromVec(b.Velocity)
+}
+
+// seek returns the closest living enemy in front of b within its seek
+// radius.
+func (g *Game) seek(b *Bullet) *Player {
+	pos := pixel.V(b.X, b.Y)
+	owner := g.playerMap[b.Owner]
+	var best *Player
+	bestDist := math.Inf(1)
+	for _, p := range g.PlayersNear(pos, b.SeekRadius) {
+		if p.UUID == b.Owner || p.Invulnerable > 0 || (owner != nil && g.Allies(owner, p)) {
+			continue
+		}
+		to := pos.To(pixel.V(p.X, p.Y))
+		if to.Dot(b.Velocity) <= 0 {
+			continue
+		}
+		if d := to.Len(); d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	return best
+}
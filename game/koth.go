@@ -0,0 +1,98 @@
+package game
+
+import (
+	"github.com/faiface/pixel"
+)
+
+// ZoneRadius is the size of the hill, ZoneSpeed how fast it drifts
+// through the arena.
+const ZoneRadius float64 = 80
+const ZoneSpeed float64 = 25
+
+// ZonePointTime is how long the hill has to be held for a point.
+const ZonePointTime float64 = 3
+
+// Zone is the hill of king of the hill. It drifts towards Target and
+// picks a new one once it got there.
+type Zone struct {
+	X      float64
+	Y      float64
+	Radius float64
+	Target pixel.Vec
+}
+
+// KingOfTheHill scores for holding the zone: players, or teams, alone in
+// it get a point every ZonePointTime. Kills score nothing.
+type KingOfTheHill struct {
+	// holder is the player or team holding the zone and held for how long
+	holder interface{}
+	held   float64
+}
+
+func (m *KingOfTheHill) Name() string                                     { return ModeKingOfTheHill }
+func (m *KingOfTheHill) Teams() int                                       { return 0 }
+func (m *KingOfTheHill) Hit(g *Game, p, attacker *Player, amount float64) {}
+func (m *KingOfTheHill) Kill(g *Game, p, killer *Player)                  {}
+func (m *KingOfTheHill) Pickup(g *Game, p *Player, k *Pickup)             {}
+
+func (m *KingOfTheHill) Start(g *Game) {
+	c := g.Bounds.Centre()
+	g.Zone = &Zone{X: c.X, Y: c.Y, Radius: ZoneRadius, Target: c}
+	m.holder, m.held = nil, 0
+}
+
+func (m *KingOfTheHill) Tick(g *Game, dt float64) {
+	z := g.Zone
+	if z == nil {
+		return
+	}
+	pos := pixel.V(z.X, z.Y)
+	to := pos.To(z.Target)
+	if step := ZoneSpeed * dt; to.Len() > step {
+		pos = pos.Add(to.Unit().Scaled(step))
+	} else {
+		pos = z.Target
+		if t, ok := g.randomFreePoint(CircleCollider(16)); ok {
+			z.Target = t
+		}
+	}
+	z.X, z.Y = pos.X, pos.Y
+
+	var inside []*Player
+	var holder interface{}
+	for _, p := range g.PlayersNear(pos, z.Radius) {
+		if pos.To(pixel.V(p.X, p.Y)).Len() > z.Radius {
+			continue
+		}
+		var side interface{} = p
+		if t := g.Team(p.Team); t != nil {
+			side = t
+		}
+		if holder != nil && holder != side {
+			// contested, nobody scores
+			m.holder, m.held = nil, 0
+			return
+		}
+		holder = side
+		inside = append(inside, p)
+	}
+	if holder == nil || holder != m.holder {
+		m.holder, m.held = holder, 0
+		return
+	}
+	m.held += dt
+	if m.held < ZonePointTime {
+		return
+	}
+	m.held -= ZonePointTime
+	for _, p := range inside {
+		p.Score++
+	}
+	if t, ok := holder.(*Team); ok {
+		t.Score++
+	}
+}
+
+func (m *KingOfTheHill) Over(g *Game) bool {
+	return g.scoreReached()
+}
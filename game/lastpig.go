@@ -0,0 +1,118 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// ShrinkDelay is the time before the arena starts to shrink, ShrinkTime
+// how long it takes to get down to ShrinkFactor of its size.
+const ShrinkDelay float64 = 30
+const ShrinkTime float64 = 90
+const ShrinkFactor float64 = 0.25
+
+// OutsideDamage is the damage per second of ships caught outside the
+// shrinking arena.
+const OutsideDamage float64 = 2
+
+// SurvivorScore is the bonus of the last players standing.
+const SurvivorScore int = 5
+
+// LastPigStanding has no respawns: killed players are eliminated until the
+// next match and the last player, or team, alive wins. The arena shrinks
+// towards its centre and hurts everybody the edge passes over. Its edges
+// are always walls, wrapping at a moving edge would throw ships across the
+// arena on every tick.
+type LastPigStanding struct {
+	// entrants are the players of the match, who joins later watches
+	entrants map[*Player]bool
+	elapsed  float64
+	hurt     float64
+	over     bool
+}
+
+func (m *LastPigStanding) Name() string                                     { return ModeLastPigStanding }
+func (m *LastPigStanding) Teams() int                                       { return 0 }
+func (m *LastPigStanding) Hit(g *Game, p, attacker *Player, amount float64) {}
+func (m *LastPigStanding) Pickup(g *Game, p *Player, k *Pickup)             {}
+
+func (m *LastPigStanding) Start(g *Game) {
+	m.entrants = make(map[*Player]bool)
+	for _, p := range g.Players {
+		m.entrants[p] = true
+	}
+	m.elapsed, m.hurt, m.over = 0, 0, false
+	g.Bounds.Edge = EdgeWall
+}
+
+func (m *LastPigStanding) Tick(g *Game, dt float64) {
+	for _, p := range g.Players {
+		if !m.entrants[p] && p.Status != Eliminated {
+			eliminate(p)
+		}
+	}
+	m.elapsed += dt
+	m.shrink(g)
+
+	m.hurt += dt
+	if m.hurt >= 1 {
+		m.hurt--
+		for _, p := range g.Players {
+			if p.Life > 0 && !g.Bounds.Contains(pixel.V(p.X, p.Y)) {
+				g.damage(p, nil, OutsideDamage)
+			}
+		}
+	}
+
+	if m.over {
+		return
+	}
+	var alive []*Player
+	for _, p := range g.Players {
+		if p.Status != Eliminated {
+			alive = append(alive, p)
+		}
+	}
+	for _, p := range alive {
+		if p.Team == 0 || p.Team != alive[0].Team {
+			if len(alive) > 1 {
+				return
+			}
+		}
+	}
+	m.over = true
+	for _, p := range alive {
+		p.Score += SurvivorScore
+	}
+	if len(alive) > 0 {
+		if t := g.Team(alive[0].Team); t != nil {
+			t.Score++
+		}
+	}
+}
+
+// shrink sizes the arena for the time into the match, centred on the
+// map.
+func (m *LastPigStanding) shrink(g *Game) {
+	f := 1 - (1-ShrinkFactor)*math.Max(0, math.Min(1, (m.elapsed-ShrinkDelay)/ShrinkTime))
+	w, h := g.Map.Width*f, g.Map.Height*f
+	g.Bounds.X, g.Bounds.Y = (g.Map.Width-w)/2, (g.Map.Height-h)/2
+	g.Bounds.Width, g.Bounds.Height = w, h
+}
+
+func (m *LastPigStanding) Kill(g *Game, p, killer *Player) {
+	g.scoreKill(p, killer)
+	eliminate(p)
+}
+
+func (m *LastPigStanding) Over(g *Game) bool {
+	return m.over
+}
+
+func eliminate(p *Player) {
+	p.Life = 0
+	p.Velocity = pixel.ZV
+	p.RespawnTime = 0
+	p.Status = Eliminated
+}
@@ -0,0 +1,68 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+	guuid "github.com/google/uuid"
+)
+
+// TestLastPigShrinkingEdge keeps a ship on the edge of a shrinking wrap
+// arena: it has to stay there and get hurt instead of wrapping around.
+func TestLastPigShrinkingEdge(t *testing.T) {
+	g := New(nil)
+	cfg := DefaultMatchConfig()
+	cfg.Mode = ModeLastPigStanding
+	cfg.Edge = EdgeWrap
+	cfg.TimeLimit = 0
+	g.SetMatchConfig(cfg)
+	p := g.NewPlayer(guuid.New())
+	g.NewPlayer(guuid.New())
+	dt := 1 / float64(g.TickRate())
+	for g.Status != Playing {
+		g.Step(dt)
+	}
+	p.X, p.Y = 0, g.Bounds.Height/2
+	p.prev = pixel.V(p.X, p.Y)
+	for s := 0.0; s < ShrinkDelay+5; s += dt {
+		x := p.X
+		g.Step(dt)
+		if math.Abs(p.X-x) > 1 {
+			t.Fatalf("ship jumped from %v to %v at %.2fs", x, p.X, s)
+		}
+	}
+	if g.Bounds.X <= 0 || p.Life >= MaxLife {
+		t.Errorf("arena starts at %v and the ship has %v life, want it shrunk and hurt", g.Bounds.X, p.Life)
+	}
+}
+
+// TestRamWhilePlaying lets two ships ram each other: in the countdown they
+// only bounce, a last pig standing player must not be out before the start.
+func TestRamWhilePlaying(t *testing.T) {
+	for _, status := range []GameStatus{Countdown, Playing} {
+		t.Run(string(status), func(t *testing.T) {
+			g := New(nil)
+			cfg := DefaultMatchConfig()
+			cfg.Mode = ModeLastPigStanding
+			g.SetMatchConfig(cfg)
+			a := g.NewPlayer(guuid.New())
+			b := g.NewPlayer(guuid.New())
+			dt := 1 / float64(g.TickRate())
+			for g.Status != status {
+				g.Step(dt)
+			}
+			c := g.Bounds.Centre()
+			a.X, a.Y, a.Velocity, a.Invulnerable = c.X, c.Y-5, pixel.V(0, PlayerMaxSpeed), 0
+			b.X, b.Y, b.Velocity, b.Invulnerable = c.X, c.Y+5, pixel.V(0, -PlayerMaxSpeed), 0
+			g.Step(dt)
+			if a.Velocity.Y >= 0 || b.Velocity.Y <= 0 {
+				t.Errorf("ships move at %v and %v, want them bounced apart", a.Velocity, b.Velocity)
+			}
+			hurt := a.Life < MaxLife && b.Life < MaxLife
+			if hurt != (status == Playing) {
+				t.Errorf("ships have %v and %v life", a.Life, b.Life)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package game
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/faiface/pixel"
+	"github.com/pkg/errors"
+)
+
+// Map describes an arena. Positions are the centres of the shapes in world
+// units, y grows upwards like in the game.
+type Map struct {
+	Name      string          `json:"name"`
+	Width     float64         `json:"width"`
+	Height    float64         `json:"height"`
+	Walls     []Wall          `json:"walls"`
+	Asteroids []Asteroid      `json:"asteroids"`
+	Spawns    []Point         `json:"spawns"`
+	Pickups   []PickupSpawner `json:"pickups"`
+	Bases     []Base          `json:"bases"`
+}
+
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Wall is a solid box, Rotation is in radians.
+type Wall struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation float64 `json:"rotation"`
+}
+
+type Asteroid struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Radius float64 `json:"radius"`
+}
+
+// Base is where the flag of Team stands in capture the flag.
+type Base struct {
+	Team int     `json:"team"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// PickupSpawner places a pickup of Kind at a point every Interval seconds
+// once the previous one was taken. Weapon pickups give Weapon, a random
+// one if it is empty.
+type PickupSpawner struct {
+	X        float64    `json:"x"`
+	Y        float64    `json:"y"`
+	Kind     PickupKind `json:"kind"`
+	Weapon   string     `json:"weapon,omitempty"`
+	Interval float64    `json:"interval"`
+}
+
+// Obstacle is a solid shape of the map. Ships bounce off obstacles and
+// bullets stop at them.
+type Obstacle struct {
+	Pos      pixel.Vec
+	Rotation RotationDegree
+	Collider Collider
+}
+
+// DefaultMap is the classic empty arena.
+func DefaultMap() *Map {
+	return &Map{
+		Name:   "default",
+		Width:  GameWidth,
+		Height: GameHeight,
+	}
+}
+
+// LoadMap reads and validates a JSON map file.
+func LoadMap(path string) (*Map, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading map")
+	}
+	var m Map
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrapf(err, "error parsing map %s", path)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid map %s", path)
+	}
+	return &m, nil
+}
+
+func (m *Map) Validate() error {
+	if m.Width <= 0 || m.Height <= 0 {
+		return errors.Errorf("arena size %vx%v", m.Width, m.Height)
+	}
+	for i, w := range m.Walls {
+		if w.Width <= 0 || w.Height <= 0 {
+			return errors.Errorf("wall %d has size %vx%v", i, w.Width, w.Height)
+		}
+	}
+	for i, a := range m.Asteroids {
+		if a.Radius <= 0 {
+			return errors.Errorf("asteroid %d has radius %v", i, a.Radius)
+		}
+	}
+	for i, s := range m.Spawns {
+		if !m.inside(s.X, s.Y) {
+			return errors.Errorf("spawn %d is outside the arena", i)
+		}
+	}
+	for i, b := range m.Bases {
+		if b.Team < 1 || b.Team > len(TeamNames) {
+			return errors.Errorf("base %d has team %d", i, b.Team)
+		}
+		if !m.inside(b.X, b.Y) {
+			return errors.Errorf("base %d is outside the arena", i)
+		}
+	}
+	for i, p := range m.Pickups {
+		if !m.inside(p.X, p.Y) {
+			return errors.Errorf("pickup %d is outside the arena", i)
+		}
+		if !validPickup(p.Kind) {
+			return errors.Errorf("pickup %d has unknown kind %q", i, p.Kind)
+		}
+		if p.Interval < 0 {
+			return errors.Errorf("pickup %d has interval %v", i, p.Interval)
+		}
+	}
+	return nil
+}
+
+func (m *Map) inside(x, y float64) bool {
+	return x >= 0 && y >= 0 && x <= m.Width && y <= m.Height
+}
+
+// Obstacles returns the solid shapes of the map.
+func (m *Map) Obstacles() []Obstacle {
+	obstacles := make([]Obstacle, 0, len(m.Walls)+len(m.Asteroids))
+	for _, w := range m.Walls {
+		obstacles = append(obstacles, Obstacle{
+			Pos:      pixel.V(w.X, w.Y),
+			Rotation: RotationDegree(w.Rotation),
+			Collider: BoxCollider(w.Width, w.Height),
+		})
+	}
+	for _, a := range m.Asteroids {
+		obstacles = append(obstacles, Obstacle{
+			Pos:      pixel.V(a.X, a.Y),
+			Collider: CircleCollider(a.Radius),
+		})
+	}
+	return obstacles
+}
+
+// indexObstacles builds the obstacle index once, obstacles never move.
+func (g *Game) indexObstacles() {
+	g.obstacleIndex.Reset(g.Map.Width, g.Map.Height, len(g.Obstacles))
+	for i, o := range g.Obstacles {
+		g.obstacleIndex.Insert(i, around(o.Pos, o.Collider.BoundingRadius()))
+	}
+}
+
+// blocked reports whether collider c at pos overlaps an obstacle.
+func (g *Game) blocked(c Collider, pos pixel.Vec, rot RotationDegree) bool {
+	hit := false
+	g.obstacleIndex.Query(around(pos, c.BoundingRadius()), func(id int) {
+		o := g.Obstacles[id]
+		if _, _, ok := Overlap(o.Collider, o.Pos, o.Rotation, c, pos, rot); ok {
+			hit = true
+		}
+	})
+	return hit
+}
+
+// collideObstacles pushes p out of the obstacles it ran into and bounces
+// it off them.
+func (g *Game) collideObstacles(p *Player) {
+	pos := pixel.V(p.X, p.Y)
+	g.obstacleIndex.Query(around(pos, p.Collider.BoundingRadius()), func(id int) {
+		o := g.Obstacles[id]
+		n, depth, ok := Overlap(o.Collider, o.Pos, o.Rotation, p.Collider, pixel.V(p.X, p.Y), p.Rotation)
+		if !ok {
+			return
+		}
+		p.X += n.X * depth
+		p.Y += n.Y * depth
+		if into := p.Velocity.Dot(n); into < 0 {
+			p.Velocity = p.Velocity.Sub(n.Scaled((1 + ShipRestitution) * into))
+		}
+	})
+}
+
+// obstacleHit returns the fraction of the path of b where it first hits
+// an obstacle.
+func (g *Game) obstacleHit(b *Bullet) (float64, bool) {
+	to := pixel.V(b.X, b.Y)
+	first, hit := 1.0, false
+	g.obstacleIndex.Query(spanning(b.prev, to, b.Collider.Radius), func(id int) {
+		o := g.Obstacles[id]
+		if t, ok := o.Collider.Sweep(o.Pos, o.Rotation, b.prev, to, b.Collider.Radius); ok && t <= first {
+			first, hit = t, true
+		}
+	})
+	return first, hit
+}
@@ -0,0 +1,106 @@
+package game
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func validMap() Map {
+	return Map{
+		Name:      "test",
+		Width:     400,
+		Height:    300,
+		Walls:     []Wall{{X: 200, Y: 150, Width: 20, Height: 100, Rotation: 0.5}},
+		Asteroids: []Asteroid{{X: 100, Y: 100, Radius: 30}},
+		Spawns:    []Point{{X: 0, Y: 0}, {X: 400, Y: 300}},
+		Pickups:   []PickupSpawner{{X: 50, Y: 250, Kind: PickupHealth, Interval: 10}},
+		Bases:     []Base{{Team: 1, X: 20, Y: 150}, {Team: 2, X: 380, Y: 150}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(m *Map)
+		err    string
+	}{
+		{"valid", func(m *Map) {}, ""},
+		{"zero width", func(m *Map) { m.Width = 0 }, "arena size"},
+		{"negative height", func(m *Map) { m.Height = -300 }, "arena size"},
+		{"flat wall", func(m *Map) { m.Walls[0].Height = 0 }, "wall 0"},
+		{"asteroid without radius", func(m *Map) { m.Asteroids[0].Radius = -1 }, "asteroid 0"},
+		{"spawn left of the arena", func(m *Map) { m.Spawns[1].X = -1 }, "spawn 1"},
+		{"spawn above the arena", func(m *Map) { m.Spawns[0].Y = 301 }, "spawn 0"},
+		{"base outside", func(m *Map) { m.Bases[1].X = 500 }, "base 1"},
+		{"base without team", func(m *Map) { m.Bases[0].Team = 0 }, "base 0"},
+		{"base of a team too many", func(m *Map) { m.Bases[0].Team = len(TeamNames) + 1 }, "base 0"},
+		{"pickup outside", func(m *Map) { m.Pickups[0].Y = -5 }, "pickup 0"},
+		{"unknown pickup", func(m *Map) { m.Pickups[0].Kind = "gold" }, "pickup 0"},
+		{"negative interval", func(m *Map) { m.Pickups[0].Interval = -1 }, "pickup 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := validMap()
+			tt.change(&m)
+			err := m.Validate()
+			if tt.err == "" && err != nil {
+				t.Errorf("got %v, want no error", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("got %v, want an error about %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name string
+		data string
+		ok   bool
+	}{
+		{"valid", `{"name":"small","width":400,"height":300,"spawns":[{"x":10,"y":10}]}`, true},
+		{"not json", `{"name":"small","width":`, false},
+		{"wrong type", `{"name":"small","width":"wide","height":300}`, false},
+		{"invalid", `{"name":"small","width":400,"height":300,"spawns":[{"x":-10,"y":10}]}`, false},
+		{"empty", `{}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.Replace(tt.name, " ", "_", -1)+".json")
+			if err := ioutil.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			m, err := LoadMap(path)
+			if (err == nil) != tt.ok {
+				t.Errorf("got %+v and %v, want ok %v", m, err, tt.ok)
+			}
+			if err != nil && !strings.Contains(err.Error(), path) {
+				t.Errorf("error %v doesn't name the file", err)
+			}
+		})
+	}
+	if _, err := LoadMap(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("loaded a missing map")
+	}
+}
+
+func TestShippedMaps(t *testing.T) {
+	paths, err := filepath.Glob("../maps/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if _, err := LoadMap(path); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package game
+
+const Countdown GameStatus = "Countdown"
+
+// MatchConfig drives the match state machine: WaitForPlayer until
+// MinPlayers joined, Countdown, Playing until ScoreLimit or TimeLimit is
+// reached, Scoreboard for ScoreboardTime and then everything again. Times
+// are in seconds, a zero limit disables it. Dead players respawn after
+// RespawnDelay and can't be hurt for SpawnProtection. On maps without
+// pickup spawners a random pickup appears every PickupInterval while there
+// are less than MaxPickups. Edge is the edge mode
+// of the arena, it takes effect when the next match starts. With Teams
+// set players are split into that many teams which score together, their
+// bullets only hurt each other with FriendlyFire. Mode names the GameMode,
+// see NewGameMode; modes that need teams get at least as many as they
+// need.
+type MatchConfig struct {
+	MinPlayers      int
+	Countdown       float64
+	ScoreLimit      int
+	TimeLimit       float64
+	ScoreboardTime  float64
+	RespawnDelay    float64
+	SpawnProtection float64
+	PickupInterval  float64
+	MaxPickups      int
+	Edge            EdgeMode
+	Teams           int
+	FriendlyFire    bool
+	Mode            string
+}
+
+func DefaultMatchConfig() MatchConfig {
+	return MatchConfig{
+		MinPlayers:      2,
+		Countdown:       5,
+		ScoreLimit:      10,
+		TimeLimit:       300,
+		ScoreboardTime:  10,
+		RespawnDelay:    3,
+		SpawnProtection: 2,
+		PickupInterval:  15,
+		MaxPickups:      3,
+		Edge:            EdgeWall,
+		Mode:            ModeDeathmatch,
+	}
+}
+
+func (g *Game) SetMatchConfig(cfg MatchConfig) {
+	if cfg.MinPlayers < 1 {
+		cfg.MinPlayers = 1
+	}
+	if cfg.Mode != g.mode.Name() {
+		mode, err := NewGameMode(cfg.Mode)
+		if err != nil {
+			mode = &Deathmatch{}
+		}
+		cfg.Mode = mode.Name()
+		g.mode = mode
+		g.Flags, g.Zone = nil, nil
+	}
+	if need := g.mode.Teams(); cfg.Teams < need {
+		cfg.Teams = need
+	}
+	// a single team is a free for all
+	if cfg.Teams < 2 {
+		cfg.Teams = 0
+	}
+	if cfg.Teams > len(TeamNames) {
+		cfg.Teams = len(TeamNames)
+	}
+	teams := cfg.Teams != g.match.Teams
+	g.match = cfg
+	if teams {
+		g.setupTeams()
+	}
+}
+
+func (g *Game) setStatus(s GameStatus, timer float64) {
+	g.Status = s
+	g.StatusTime = timer
+	g.emit(Event{Type: EventStatus, Status: s, Value: timer})
+}
+
+// updateMatch advances the match state machine by dt.
+func (g *Game) updateMatch(dt float64) {
+	if g.StatusTime > 0 {
+		g.StatusTime -= dt
+	}
+	switch g.Status {
+	case WaitForPlayer:
+		if len(g.Players) >= g.match.MinPlayers {
+			g.setStatus(Countdown, g.match.Countdown)
+		}
+	case Countdown:
+		if len(g.Players) < g.match.MinPlayers {
+			g.setStatus(WaitForPlayer, 0)
+			break
+		}
+		if g.StatusTime <= 0 {
+			g.startMatch()
+		}
+	case Playing:
+		if len(g.Players) < g.match.MinPlayers || g.matchOver() {
+			g.setStatus(Scoreboard, g.match.ScoreboardTime)
+		}
+	case Scoreboard:
+		if g.StatusTime <= 0 {
+			g.resetBounds()
+			g.setStatus(WaitForPlayer, 0)
+		}
+	}
+}
+
+// resetBounds brings back the whole arena after a mode shrank it.
+func (g *Game) resetBounds() {
+	g.Bounds.X, g.Bounds.Y = 0, 0
+	g.Bounds.Width, g.Bounds.Height = g.Map.Width, g.Map.Height
+}
+
+func (g *Game) matchOver() bool {
+	if g.match.TimeLimit > 0 && g.StatusTime <= 0 {
+		return true
+	}
+	return g.mode.Over(g)
+}
+
+// startMatch puts every player back to a fresh spawn and clears the arena.
+func (g *Game) startMatch() {
+	g.Bullets = nil
+	g.indexBullets()
+	g.resetPickups()
+	g.resetBounds()
+	if g.match.Edge != "" {
+		g.Bounds.Edge = g.match.Edge
+	}
+	g.balanceTeams()
+	for _, t := range g.Teams {
+		t.Score = 0
+	}
+	for _, p := range g.Players {
+		p.Score = 0
+		p.Hits, p.DamageDealt = 0, 0
+		p.Kills, p.Deaths, p.Assists = 0, 0, 0
+		g.spawn(p)
+	}
+	g.mode.Start(g)
+	g.setStatus(Playing, g.match.TimeLimit)
+}
@@ -0,0 +1,83 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	guuid "github.com/google/uuid"
+)
+
+type statusChange struct {
+	status GameStatus
+	at     float64
+}
+
+// TestMatchCycle plays a match from the first player to the next
+// countdown and checks when the status events come.
+func TestMatchCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		kill  bool
+		leave bool
+		want  []statusChange
+	}{
+		{"time limit", false, false, []statusChange{
+			{Countdown, 0.5}, {Playing, 1.5}, {Scoreboard, 3.5}, {WaitForPlayer, 4.5}, {Countdown, 4.5},
+		}},
+		{"score limit", true, false, []statusChange{
+			{Countdown, 0.5}, {Playing, 1.5}, {Scoreboard, 1.5}, {WaitForPlayer, 2.5}, {Countdown, 2.5},
+		}},
+		{"player left", false, true, []statusChange{
+			{Countdown, 0.5}, {Playing, 1.5}, {Scoreboard, 1.5}, {WaitForPlayer, 2.5},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New(nil)
+			g.SetMatchConfig(MatchConfig{
+				MinPlayers:     2,
+				Countdown:      1,
+				ScoreLimit:     1,
+				TimeLimit:      2,
+				ScoreboardTime: 1,
+				Mode:           ModeDeathmatch,
+			})
+			a := g.NewPlayer(guuid.New())
+			var b *Player
+			killed := false
+			dt := 1 / float64(g.TickRate())
+			var got []statusChange
+			for s := 0.0; s < 5; s += dt {
+				if b == nil && s >= 0.5 {
+					b = g.NewPlayer(guuid.New())
+				}
+				if g.Status == Playing && tt.kill && !killed {
+					b.Invulnerable = 0
+					g.damage(b, a, MaxLife)
+					killed = true
+				}
+				if g.Status == Playing && tt.leave && g.GetPlayer(b.UUID) != nil {
+					g.DeletePlayer(b.UUID)
+				}
+				g.Step(dt)
+				for _, e := range g.TakeEvents() {
+					if e.Type == EventStatus {
+						got = append(got, statusChange{e.Status, s})
+					}
+				}
+			}
+			if len(got) < len(tt.want) {
+				t.Fatalf("status changes %v, want %v first", got, tt.want)
+			}
+			for i, w := range tt.want {
+				if got[i].status != w.status || math.Abs(got[i].at-w.at) > 4*dt {
+					t.Errorf("status changes %v, want %v first", got, tt.want)
+					break
+				}
+			}
+			if tt.leave && len(got) > len(tt.want) {
+				t.Errorf("status changes %v after the last player left", got[len(tt.want):])
+			}
+		})
+	}
+}
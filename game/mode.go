@@ -0,0 +1,117 @@
+package game
+
+import "github.com/pkg/errors"
+
+const ModeDeathmatch = "deathmatch"
+const ModeCaptureTheFlag = "ctf"
+const ModeKingOfTheHill = "koth"
+const ModeLastPigStanding = "lastpig"
+
+// GameMode holds the rules of a match on top of the basics every mode
+// shares: moving, shooting, damage and respawns. The game calls the hooks
+// from its own step, a mode only changes the game from within them.
+type GameMode interface {
+	Name() string
+	// Teams is the number of teams the mode needs, 0 if it works with any.
+	Teams() int
+	// Start is called when a match starts, after every player spawned.
+	Start(g *Game)
+	// Tick is called every step of a match after everything moved.
+	Tick(g *Game, dt float64)
+	// Hit is called when attacker, which may be nil, hurt p by amount.
+	Hit(g *Game, p, attacker *Player, amount float64)
+	// Kill is called when p died, killer may be nil.
+	Kill(g *Game, p, killer *Player)
+	// Pickup is called when p collected k.
+	Pickup(g *Game, p *Player, k *Pickup)
+	// Over reports whether somebody won the match. The time limit is
+	// checked by the game.
+	Over(g *Game) bool
+}
+
+// NewGameMode returns a fresh mode called name.
+func NewGameMode(name string) (GameMode, error) {
+	switch name {
+	case ModeDeathmatch:
+		return &Deathmatch{}, nil
+	case ModeCaptureTheFlag:
+		return &CaptureTheFlag{}, nil
+	case ModeKingOfTheHill:
+		return &KingOfTheHill{}, nil
+	case ModeLastPigStanding:
+		return &LastPigStanding{}, nil
+	}
+	return nil, errors.Errorf("unknown game mode %q", name)
+}
+
+// Mode returns the mode of the current match.
+func (g *Game) Mode() GameMode {
+	return g.mode
+}
+
+// Deathmatch is every player, or every team, for themselves: kills score
+// and the first to reach the score limit wins.
+type Deathmatch struct{}
+
+func (m *Deathmatch) Name() string                                     { return ModeDeathmatch }
+func (m *Deathmatch) Teams() int                                       { return 0 }
+func (m *Deathmatch) Start(g *Game)                                    {}
+func (m *Deathmatch) Tick(g *Game, dt float64)                         {}
+func (m *Deathmatch) Hit(g *Game, p, attacker *Player, amount float64) {}
+func (m *Deathmatch) Pickup(g *Game, p *Player, k *Pickup)             {}
+
+func (m *Deathmatch) Kill(g *Game, p, killer *Player) {
+	if !g.scoreKill(p, killer) {
+		return
+	}
+	if t := g.Team(killer.Team); t != nil {
+		t.Score++
+	}
+}
+
+func (m *Deathmatch) Over(g *Game) bool {
+	return g.scoreReached()
+}
+
+// scoreKill gives killer a point for killing p and reports whether it was
+// an enemy kill. Killing a teammate costs a point.
+func (g *Game) scoreKill(p, killer *Player) bool {
+	switch {
+	case killer == nil || killer == p:
+		return false
+	case g.Allies(killer, p):
+		killer.Score--
+		return false
+	}
+	killer.Score++
+	return true
+}
+
+// scoreReached reports whether a team, or in a free for all a player,
+// reached the score limit.
+func (g *Game) scoreReached() bool {
+	if g.match.ScoreLimit <= 0 {
+		return false
+	}
+	if len(g.Teams) > 0 {
+		return g.teamScoreReached()
+	}
+	for _, p := range g.Players {
+		if p.Score >= g.match.ScoreLimit {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) teamScoreReached() bool {
+	if g.match.ScoreLimit <= 0 {
+		return false
+	}
+	for _, t := range g.Teams {
+		if t.Score >= g.match.ScoreLimit {
+			return true
+		}
+	}
+	return false
+}
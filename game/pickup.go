@@ -0,0 +1,204 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+type PickupKind string
+
+const PickupHealth PickupKind = "health"
+const PickupRapidFire PickupKind = "rapid_fire"
+const PickupDamage PickupKind = "damage"
+const PickupShield PickupKind = "shield"
+const PickupSpeed PickupKind = "speed"
+const PickupWeapon PickupKind = "weapon"
+
+// PickupKinds lists every kind, random pickups are drawn from it.
+var PickupKinds = []PickupKind{PickupHealth, PickupRapidFire, PickupDamage, PickupShield, PickupSpeed, PickupWeapon}
+
+const MaxLife float64 = 10
+
+// HealthPickup is the life a health pickup gives back, up to MaxLife.
+const HealthPickup float64 = 5
+
+// Timed pickups last EffectDuration seconds, the shield ShieldDuration.
+const EffectDuration float64 = 10
+const ShieldDuration float64 = 5
+
+// RapidFireFactor scales the reload delay, DamageBoostFactor the bullet
+// damage and SpeedBoostFactor acceleration and top speed.
+const RapidFireFactor float64 = 0.4
+const DamageBoostFactor float64 = 2
+const SpeedBoostFactor float64 = 1.5
+
+// PickupRespawnDelay is used for map spawners without an interval.
+const PickupRespawnDelay float64 = 20
+
+var PickupCollider = CircleCollider(8)
+
+type Pickup struct {
+	NetID uint32
+	Kind  PickupKind
+	// Weapon is the weapon given by weapon pickups
+	Weapon   *Weapon
+	X        float64
+	Y        float64
+	Collider Collider
+	// spawner is the index of the map spawner, -1 for random pickups
+	spawner int
+}
+
+// Effects are the seconds left of the timed pickups of a player.
+type Effects struct {
+	RapidFire float64
+	Damage    float64
+	Shield    float64
+	Speed     float64
+}
+
+func (e *Effects) update(dt float64) {
+	e.RapidFire = math.Max(0, e.RapidFire-dt)
+	e.Damage = math.Max(0, e.Damage-dt)
+	e.Shield = math.Max(0, e.Shield-dt)
+	e.Speed = math.Max(0, e.Speed-dt)
+}
+
+// spawner is the state of a map pickup spawner: the pickup it placed or
+// the time until it places the next one.
+type spawner struct {
+	pickup *Pickup
+	timer  float64
+}
+
+func validPickup(k PickupKind) bool {
+	for _, kind := range PickupKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// resetPickups clears the arena for a new match. Map spawners place their
+// pickups right away, random ones come after the first interval.
+func (g *Game) resetPickups() {
+	g.Pickups = nil
+	g.spawners = make([]spawner, len(g.Map.Pickups))
+	g.pickupTimer = g.match.PickupInterval
+}
+
+// updatePickups runs the effect timers and, during a match, the pickup
+// spawners. Maps with spawners only get their pickups, the others get a
+// random one every PickupInterval up to MaxPickups.
+func (g *Game) updatePickups(dt float64) {
+	for _, p := range g.Players {
+		p.Effects.update(dt)
+	}
+	if g.Status != Playing {
+		return
+	}
+	if len(g.Map.Pickups) > 0 {
+		for i := range g.spawners {
+			s := &g.spawners[i]
+			if s.pickup != nil {
+				continue
+			}
+			s.timer -= dt
+			if s.timer > 0 {
+				continue
+			}
+			sp := g.Map.Pickups[i]
+			s.pickup = g.addPickup(sp.Kind, sp.Weapon, pixel.V(sp.X, sp.Y), i)
+		}
+		return
+	}
+	if g.match.PickupInterval <= 0 {
+		return
+	}
+	g.pickupTimer -= dt
+	if g.pickupTimer > 0 {
+		return
+	}
+	g.pickupTimer = g.match.PickupInterval
+	if len(g.Pickups) >= g.match.MaxPickups {
+		return
+	}
+	if pos, ok := g.randomFreePoint(PickupCollider); ok {
+		g.addPickup(PickupKinds[rand.Intn(len(PickupKinds))], "", pos, -1)
+	}
+}
+
+// addPickup places a pickup of kind. Weapon pickups give the weapon
+// called weapon, a random one if it is empty or unknown.
+func (g *Game) addPickup(kind PickupKind, weapon string, pos pixel.Vec, source int) *Pickup {
+	var w *Weapon
+	if kind == PickupWeapon {
+		w = g.weapons.Get(weapon)
+		if w == nil {
+			w = g.weapons.random()
+		}
+		if w == nil {
+			kind = PickupHealth
+		}
+	}
+	k := &Pickup{
+		NetID:    g.newNetID(),
+		Kind:     kind,
+		Weapon:   w,
+		X:        pos.X,
+		Y:        pos.Y,
+		Collider: PickupCollider,
+		spawner:  source,
+	}
+	g.Pickups = append(g.Pickups, k)
+	return k
+}
+
+// collectPickups gives every pickup touched by a living ship to the
+// first of them.
+func (g *Game) collectPickups() {
+	for i := len(g.Pickups) - 1; i >= 0; i-- {
+		k := g.Pickups[i]
+		pos := pixel.V(k.X, k.Y)
+		for _, p := range g.PlayersNear(pos, k.Collider.Radius) {
+			if _, _, ok := Overlap(p.Collider, pixel.V(p.X, p.Y), p.Rotation, k.Collider, pos, 0); !ok {
+				continue
+			}
+			g.collect(p, k)
+			g.Pickups = append(g.Pickups[:i], g.Pickups[i+1:]...)
+			break
+		}
+	}
+}
+
+func (g *Game) collect(p *Player, k *Pickup) {
+	var value float64
+	switch k.Kind {
+	case PickupHealth:
+		value = math.Min(HealthPickup, MaxLife-p.Life)
+		p.Life += value
+	case PickupRapidFire:
+		p.Effects.RapidFire, value = EffectDuration, EffectDuration
+	case PickupDamage:
+		p.Effects.Damage, value = EffectDuration, EffectDuration
+	case PickupShield:
+		p.Effects.Shield, value = ShieldDuration, ShieldDuration
+	case PickupSpeed:
+		p.Effects.Speed, value = EffectDuration, EffectDuration
+	case PickupWeapon:
+		g.giveWeapon(p, k.Weapon)
+		value = float64(k.Weapon.Ammo)
+	}
+	if k.spawner >= 0 {
+		interval := g.Map.Pickups[k.spawner].Interval
+		if interval <= 0 {
+			interval = PickupRespawnDelay
+		}
+		g.spawners[k.spawner] = spawner{timer: interval}
+	}
+	g.emit(Event{Type: EventPickup, Player: p, Pickup: k.Kind, Value: value})
+	g.mode.Pickup(g, p, k)
+}
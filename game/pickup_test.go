@@ -0,0 +1,126 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+	guuid "github.com/google/uuid"
+)
+
+func TestPickupHealth(t *testing.T) {
+	for _, life := range []float64{3, 8} {
+		g := New(nil)
+		p := g.NewPlayer(guuid.New())
+		p.Life = life
+		g.collect(p, g.addPickup(PickupHealth, "", pixel.V(p.X, p.Y), -1))
+		if want := math.Min(life+HealthPickup, MaxLife); p.Life != want {
+			t.Errorf("health pickup brought %v life to %v, want %v", life, p.Life, want)
+		}
+	}
+}
+
+// TestPickupEffects collects every timed pickup and checks that it works
+// until it runs out.
+func TestPickupEffects(t *testing.T) {
+	tests := []struct {
+		kind     PickupKind
+		duration float64
+		left     func(p *Player) float64
+		// boosted tries the effect on p
+		boosted func(g *Game, p *Player) bool
+	}{
+		{PickupRapidFire, EffectDuration, func(p *Player) float64 { return p.Effects.RapidFire }, func(g *Game, p *Player) bool {
+			g.fire(p)
+			return p.ReloadTime < p.Weapon().Reload
+		}},
+		{PickupDamage, EffectDuration, func(p *Player) float64 { return p.Effects.Damage }, func(g *Game, p *Player) bool {
+			g.fire(p)
+			return g.Bullets[len(g.Bullets)-1].Damage > p.Weapon().Damage*p.Power
+		}},
+		{PickupShield, ShieldDuration, func(p *Player) float64 { return p.Effects.Shield }, func(g *Game, p *Player) bool {
+			life := p.Life
+			p.Invulnerable = 0
+			g.damage(p, nil, 1)
+			hurt := p.Life < life
+			p.Life = life
+			return !hurt
+		}},
+		{PickupSpeed, EffectDuration, func(p *Player) float64 { return p.Effects.Speed }, func(g *Game, p *Player) bool {
+			c := g.Bounds.Centre()
+			p.X, p.Y, p.Velocity = c.X, c.Y, pixel.V(2*PlayerMaxSpeed, 0)
+			p.MovePlayer(1/float64(g.TickRate()), g.Bounds)
+			return p.Velocity.Len() > PlayerMaxSpeed
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			g := New(nil)
+			p := g.NewPlayer(guuid.New())
+			if tt.boosted(g, p) {
+				t.Fatal("boosted before the pickup")
+			}
+			g.addPickup(tt.kind, "", pixel.V(p.X, p.Y), -1)
+			dt := 1 / float64(g.TickRate())
+			g.Step(dt)
+			if len(g.Pickups) != 0 || tt.left(p) != tt.duration {
+				t.Fatalf("%d pickups left and the effect lasts %v, want it collected for %v", len(g.Pickups), tt.left(p), tt.duration)
+			}
+			if !tt.boosted(g, p) {
+				t.Error("not boosted after the pickup")
+			}
+			run := func(seconds float64) {
+				for s := 0.0; s < seconds; s += dt {
+					g.Step(dt)
+				}
+			}
+			run(tt.duration - 0.5)
+			if tt.left(p) <= 0 || !tt.boosted(g, p) {
+				t.Errorf("effect ran out %vs early", tt.left(p))
+			}
+			run(1)
+			if tt.left(p) != 0 || tt.boosted(g, p) {
+				t.Errorf("effect lasts %vs longer, want it gone", tt.left(p))
+			}
+		})
+	}
+}
+
+func TestRandomPickups(t *testing.T) {
+	g := New(nil)
+	cfg := DefaultMatchConfig()
+	cfg.MinPlayers = 1
+	cfg.Countdown = 0
+	cfg.TimeLimit = 0
+	cfg.PickupInterval = 1
+	g.SetMatchConfig(cfg)
+	p := g.NewPlayer(guuid.New())
+	dt := 1 / float64(g.TickRate())
+	for g.Status != Playing {
+		g.Step(dt)
+	}
+	// a dead ship can't collect anything
+	p.Life, p.Status, p.RespawnTime = 0, Died, 100
+	run := func(seconds float64) {
+		for s := 0.0; s < seconds; s += dt {
+			g.Step(dt)
+		}
+	}
+	run(0.5)
+	if n := len(g.Pickups); n != 0 {
+		t.Errorf("%d pickups before the first interval", n)
+	}
+	run(1)
+	if n := len(g.Pickups); n != 1 {
+		t.Errorf("%d pickups after the first interval, want 1", n)
+	}
+	run(10)
+	if n := len(g.Pickups); n != cfg.MaxPickups {
+		t.Errorf("%d pickups, want %d at most", n, cfg.MaxPickups)
+	}
+	g.Pickups = g.Pickups[1:]
+	run(1.5)
+	if n := len(g.Pickups); n != cfg.MaxPickups {
+		t.Errorf("%d pickups after one was taken, want %d again", n, cfg.MaxPickups)
+	}
+}
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+// spawnCandidates is the number of random points tried when looking for
+// a safe place to spawn.
+const spawnCandidates = 16
+
+// spawnMargin keeps spawn points away from the arena edges.
+const spawnMargin float64 = 32
+
+// die marks p as dead and starts its respawn timer. killer may be nil.
+func (g *Game) die(p, killer *Player) {
+	p.Life = 0
+	p.Velocity = pixel.ZV
+	p.Status = Died
+	p.RespawnTime = g.match.RespawnDelay
+	g.emit(Event{Type: EventDied, Player: p, Other: killer, Value: p.RespawnTime})
+}
+
+// updateRespawns counts down the respawn timers of dead players and the
+// spawn protection of freshly spawned ones.
+func (g *Game) updateRespawns(dt float64) {
+	for _, p := range g.Players {
+		switch p.Status {
+		case Died:
+			p.RespawnTime -= dt
+			if p.RespawnTime <= 0 {
+				g.spawn(p)
+			}
+		case Respawn:
+			p.Invulnerable -= dt
+			if p.Invulnerable <= 0 {
+				p.Invulnerable = 0
+				p.Status = Ready
+			}
+		}
+	}
+}
+
+// spawn puts p back into the arena at a safe spot with full health and
+// temporary invulnerability.
+func (g *Game) spawn(p *Player) {
+	pos := g.safeSpawnPoint(p)
+	p.X, p.Y = pos.X, pos.Y
+	p.Velocity = pixel.ZV
+	p.Life = MaxLife
+	p.Effects = Effects{}
+	g.resetInventory(p)
+	p.ReloadTime = SpawnReloadDelay
+	p.RespawnTime = 0
+	p.Invulnerable = g.match.SpawnProtection
+	p.attackers = nil
+	p.Status = Respawn
+	g.emit(Event{Type: EventSpawn, Player: p})
+}
+
+// safeSpawnPoint returns the candidate point farthest away from the
+// closest living enemy of p. Candidates are the spawn points of the map or,
+// if it has none, random free points in the arena.
+func (g *Game) safeSpawnPoint(p *Player) pixel.Vec {
+	var best pixel.Vec
+	bestDist := -1.0
+	for _, c := range g.spawnCandidates(p) {
+		d := math.Inf(1)
+		for _, o := range g.Players {
+			if o == p || o.Life <= 0 || g.Allies(o, p) {
+				continue
+			}
+			d = math.Min(d, c.To(pixel.V(o.X, o.Y)).Len())
+		}
+		if d > bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func (g *Game) spawnCandidates(p *Player) []pixel.Vec {
+	candidates := make([]pixel.Vec, 0, spawnCandidates)
+	if len(g.Map.Spawns) > 0 {
+		for _, s := range g.Map.Spawns {
+			candidates = append(candidates, pixel.V(s.X, s.Y))
+		}
+		return candidates
+	}
+	for i := 0; i < spawnCandidates; i++ {
+		if c, ok := g.randomFreePoint(p.Collider); ok {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, g.Bounds.Centre())
+	}
+	return candidates
+}
+
+// randomFreePoint picks a random point in the arena where c does not
+// overlap an obstacle. It gives up after a few tries.
+func (g *Game) randomFreePoint(c Collider) (pixel.Vec, bool) {
+	for i := 0; i < 8; i++ {
+		pos := pixel.V(
+			g.Bounds.X+spawnMargin+rand.Float64()*(g.Bounds.Width-2*spawnMargin),
+			g.Bounds.Y+spawnMargin+rand.Float64()*(g.Bounds.Height-2*spawnMargin),
+		)
+		if !g.blocked(c, pos, 0) {
+			return pos, true
+		}
+	}
+	return pixel.ZV, false
+}
@@ -0,0 +1,185 @@
+package game
+
+import (
+	"math"
+	"sort"
+
+	"github.com/faiface/pixel"
+)
+
+// SpatialCellSize is the cell edge of the game's spatial indexes. It is a
+// few ship lengths, so most queries touch four cells or less.
+const SpatialCellSize float64 = 64
+
+// SpatialHash is a uniform grid over the arena for broadphase queries.
+// Entities are stored by index with their bounding rectangle and may span
+// several cells; entities outside the arena are kept in the border cells.
+type SpatialHash struct {
+	cellSize   float64
+	cols, rows int
+	cells      [][]int
+	// seen deduplicates entities spanning several cells within one query
+	seen  []uint32
+	stamp uint32
+}
+
+func NewSpatialHash(cellSize float64) *SpatialHash {
+	return &SpatialHash{cellSize: cellSize}
+}
+
+// Reset empties the grid and sizes it for an arena of width x height
+// holding up to n entities. Cell storage is reused between resets.
+func (h *SpatialHash) Reset(width, height float64, n int) {
+	cols := int(math.Ceil(width/h.cellSize)) + 1
+	rows := int(math.Ceil(height/h.cellSize)) + 1
+	if cols != h.cols || rows != h.rows {
+		h.cols, h.rows = cols, rows
+		h.cells = make([][]int, cols*rows)
+	} else {
+		for i := range h.cells {
+			h.cells[i] = h.cells[i][:0]
+		}
+	}
+	if cap(h.seen) < n {
+		h.seen = make([]uint32, n)
+		h.stamp = 0
+	}
+	h.seen = h.seen[:n]
+}
+
+func (h *SpatialHash) cell(v pixel.Vec) (int, int) {
+	x := int(math.Floor(v.X / h.cellSize))
+	y := int(math.Floor(v.Y / h.cellSize))
+	if x < 0 {
+		x = 0
+	} else if x >= h.cols {
+		x = h.cols - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= h.rows {
+		y = h.rows - 1
+	}
+	return x, y
+}
+
+// Insert adds entity id covering the rectangle r.
+func (h *SpatialHash) Insert(id int, r pixel.Rect) {
+	x0, y0 := h.cell(r.Min)
+	x1, y1 := h.cell(r.Max)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			i := y*h.cols + x
+			h.cells[i] = append(h.cells[i], id)
+		}
+	}
+}
+
+// Query calls fn once for every entity whose cells overlap r. The caller
+// still has to do the exact test.
+func (h *SpatialHash) Query(r pixel.Rect, fn func(id int)) {
+	if len(h.cells) == 0 {
+		return
+	}
+	h.stamp++
+	if h.stamp == 0 {
+		for i := range h.seen {
+			h.seen[i] = 0
+		}
+		h.stamp = 1
+	}
+	x0, y0 := h.cell(r.Min)
+	x1, y1 := h.cell(r.Max)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			for _, id := range h.cells[y*h.cols+x] {
+				if h.seen[id] == h.stamp {
+					continue
+				}
+				h.seen[id] = h.stamp
+				fn(id)
+			}
+		}
+	}
+}
+
+// around returns the square of half size r around c.
+func around(c pixel.Vec, r float64) pixel.Rect {
+	return pixel.R(c.X-r, c.Y-r, c.X+r, c.Y+r)
+}
+
+// spanning returns the rectangle covering a circle of radius r moving from
+// a to b.
+func spanning(a, b pixel.Vec, r float64) pixel.Rect {
+	return pixel.R(
+		math.Min(a.X, b.X)-r, math.Min(a.Y, b.Y)-r,
+		math.Max(a.X, b.X)+r, math.Max(a.Y, b.Y)+r,
+	)
+}
+
+// indexPlayers rebuilds the player index. Each living ship covers its
+// path of the current step.
+func (g *Game) indexPlayers() {
+	g.playerIndex.Reset(g.Map.Width, g.Map.Height, len(g.Players))
+	for i, p := range g.Players {
+		if p.Life <= 0 {
+			continue
+		}
+		g.playerIndex.Insert(i, spanning(p.prev, pixel.V(p.X, p.Y), p.Collider.BoundingRadius()))
+	}
+}
+
+func (g *Game) indexBullets() {
+	g.bulletIndex.Reset(g.Map.Width, g.Map.Height, len(g.Bullets))
+	for i, b := range g.Bullets {
+		g.bulletIndex.Insert(i, around(pixel.V(b.X, b.Y), b.Collider.BoundingRadius()))
+	}
+}
+
+// nearPath returns the indexes of the players whose cells touch the path
+// of a circle of radius r from a to b, in ascending order. The slice is
+// reused by the next call.
+func (g *Game) nearPath(a, b pixel.Vec, r float64) []int {
+	g.candidates = g.candidates[:0]
+	g.playerIndex.Query(spanning(a, b, r), func(id int) {
+		g.candidates = append(g.candidates, id)
+	})
+	sort.Ints(g.candidates)
+	return g.candidates
+}
+
+// PlayersNear returns the living players within radius of pos, in the
+// order of g.Players.
+func (g *Game) PlayersNear(pos pixel.Vec, radius float64) []*Player {
+	var ids []int
+	g.playerIndex.Query(around(pos, radius), func(id int) {
+		p := g.Players[id]
+		if p.Life > 0 && pos.To(pixel.V(p.X, p.Y)).Len() <= radius+p.Collider.BoundingRadius() {
+			ids = append(ids, id)
+		}
+	})
+	sort.Ints(ids)
+	players := make([]*Player, len(ids))
+	for i, id := range ids {
+		players[i] = g.Players[id]
+	}
+	return players
+}
+
+// BulletsNear returns the bullets within radius of pos, in the order of
+// g.Bullets.
+func (g *Game) BulletsNear(pos pixel.Vec, radius float64) []*Bullet {
+	var ids []int
+	g.bulletIndex.Query(around(pos, radius), func(id int) {
+		b := g.Bullets[id]
+		if pos.To(pixel.V(b.X, b.Y)).Len() <= radius+b.Collider.BoundingRadius() {
+			ids = append(ids, id)
+		}
+	})
+	sort.Ints(ids)
+	bullets := make([]*Bullet, len(ids))
+	for i, id := range ids {
+		bullets[i] = g.Bullets[id]
+	}
+	return bullets
+}
@@ -0,0 +1,85 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+	guuid "github.com/google/uuid"
+)
+
+// crowd returns a playing game with the given number of ships and bullets
+// scattered over the arena.
+func crowd(players, bullets int) *Game {
+	rand.Seed(1)
+	g := New(nil)
+	g.Status = Playing
+	for i := 0; i < players; i++ {
+		p := g.NewPlayer(guuid.New())
+		p.X, p.Y = rand.Float64()*g.Bounds.Width, rand.Float64()*g.Bounds.Height
+		p.prev = pixel.V(p.X, p.Y)
+		p.Invulnerable = 0
+		p.Status = Ready
+	}
+	blaster := g.weapons.Get(g.weapons.Default)
+	for i := 0; i < bullets; i++ {
+		owner := g.Players[rand.Intn(players)]
+		g.AddBullet(rand.Float64()*g.Bounds.Width, rand.Float64()*g.Bounds.Height, owner.UUID,
+			RotationDegree(rand.Float64()*2*math.Pi), blaster, 1, pixel.ZV)
+	}
+	return g
+}
+
+func BenchmarkCollision(b *testing.B) {
+	sizes := []struct{ players, bullets int }{{16, 200}, {200, 5000}}
+	for _, size := range sizes {
+		name := fmt.Sprintf("players=%d,bullets=%d", size.players, size.bullets)
+		g := crowd(size.players, size.bullets)
+		bullets := make([]Bullet, len(g.Bullets))
+		for i, bl := range g.Bullets {
+			bullets[i] = *bl
+		}
+		b.Run("grid/"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				g.Bullets = g.Bullets[:0]
+				for j := range bullets {
+					bl := bullets[j]
+					g.Bullets = append(g.Bullets, &bl)
+				}
+				for _, p := range g.Players {
+					p.Life = 10
+				}
+				b.StartTimer()
+				g.Collision()
+			}
+		})
+		b.Run("bruteforce/"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				hits := 0
+				for _, bl := range bullets {
+					for _, p := range g.Players {
+						pos := pixel.V(p.X, p.Y)
+						if _, hit := p.Collider.Sweep(pos, p.Rotation, bl.prev, pixel.V(bl.X, bl.Y), bl.Collider.Radius); hit {
+							hits++
+						}
+					}
+				}
+				_ = hits
+			}
+		})
+	}
+}
+
+func BenchmarkPlayersNear(b *testing.B) {
+	g := crowd(200, 5000)
+	g.Collision()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := g.Players[i%len(g.Players)]
+		g.PlayersNear(pixel.V(p.X, p.Y), 200)
+		g.BulletsNear(pixel.V(p.X, p.Y), 100)
+	}
+}
@@ -0,0 +1,105 @@
+package game
+
+// TeamNames and TeamColors name and colour the teams in order, there can
+// be as many teams as there are names.
+var TeamNames = []string{"red", "blue", "green", "yellow"}
+var TeamColors = []string{"#e0403a", "#3a7be0", "#3ac45a", "#e0c03a"}
+
+// Team is one side of a team match. Its Score grows with every kill of a
+// member. Players refer to their team by ID, 0 is no team.
+type Team struct {
+	ID    int
+	Name  string
+	Color string
+	Score int
+}
+
+// setupTeams creates the teams of the match config and puts every player
+// into one of them.
+func (g *Game) setupTeams() {
+	g.Teams = nil
+	for i := 0; i < g.match.Teams; i++ {
+		g.Teams = append(g.Teams, &Team{ID: i + 1, Name: TeamNames[i], Color: TeamColors[i]})
+	}
+	for _, p := range g.Players {
+		p.Team = 0
+	}
+	for _, p := range g.Players {
+		g.assignTeam(p)
+	}
+}
+
+// Team returns the team with id or nil.
+func (g *Game) Team(id int) *Team {
+	if id < 1 || id > len(g.Teams) {
+		return nil
+	}
+	return g.Teams[id-1]
+}
+
+// assignTeam puts p into the team with the fewest members, the first one
+// of them on a tie.
+func (g *Game) assignTeam(p *Player) {
+	p.Team = 0
+	if len(g.Teams) == 0 {
+		return
+	}
+	sizes := g.teamSizes()
+	best := 1
+	for id := 2; id <= len(g.Teams); id++ {
+		if sizes[id] < sizes[best] {
+			best = id
+		}
+	}
+	p.Team = best
+}
+
+// teamSizes counts the members of every team, indexed by team id.
+func (g *Game) teamSizes() []int {
+	sizes := make([]int, len(g.Teams)+1)
+	for _, p := range g.Players {
+		sizes[p.Team]++
+	}
+	return sizes
+}
+
+// balanceTeams moves the latest players of the biggest team to the
+// smallest one until they differ by one player at most. Players that left
+// can leave the teams uneven, this evens them out between matches.
+func (g *Game) balanceTeams() {
+	if len(g.Teams) < 2 {
+		return
+	}
+	for {
+		sizes := g.teamSizes()
+		small, big := 1, 1
+		for id := 2; id <= len(g.Teams); id++ {
+			if sizes[id] < sizes[small] {
+				small = id
+			}
+			if sizes[id] > sizes[big] {
+				big = id
+			}
+		}
+		if sizes[big]-sizes[small] <= 1 {
+			return
+		}
+		for i := len(g.Players) - 1; i >= 0; i-- {
+			if g.Players[i].Team == big {
+				g.Players[i].Team = small
+				break
+			}
+		}
+	}
+}
+
+// Allies reports whether a and b are different players of the same team.
+func (g *Game) Allies(a, b *Player) bool {
+	return a != b && a.Team != 0 && a.Team == b.Team
+}
+
+// CanHurt reports whether attacker may hurt p, teammates only hurt each
+// other with friendly fire on.
+func (g *Game) CanHurt(attacker, p *Player) bool {
+	return attacker == nil || !g.Allies(attacker, p) || g.match.FriendlyFire
+}
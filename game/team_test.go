@@ -0,0 +1,94 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+
+	guuid "github.com/google/uuid"
+)
+
+func teamGame(teams int, friendlyFire bool) *Game {
+	g := New(nil)
+	cfg := DefaultMatchConfig()
+	cfg.Teams = teams
+	cfg.FriendlyFire = friendlyFire
+	cfg.Countdown = 1
+	g.SetMatchConfig(cfg)
+	return g
+}
+
+func teamsOf(g *Game) []int {
+	var teams []int
+	for _, p := range g.Players {
+		teams = append(teams, p.Team)
+	}
+	return teams
+}
+
+func TestAssignTeam(t *testing.T) {
+	g := teamGame(3, false)
+	for i := 0; i < 7; i++ {
+		g.NewPlayer(guuid.New())
+	}
+	if want := []int{1, 2, 3, 1, 2, 3, 1}; !reflect.DeepEqual(teamsOf(g), want) {
+		t.Errorf("teams are %v, want %v", teamsOf(g), want)
+	}
+	// a free place goes to the next player
+	g.DeletePlayer(g.Players[4].UUID)
+	if p := g.NewPlayer(guuid.New()); p.Team != 2 {
+		t.Errorf("player joined team %d, want the smaller team 2", p.Team)
+	}
+}
+
+func TestBalanceTeams(t *testing.T) {
+	g := teamGame(2, false)
+	var ps []*Player
+	for i := 0; i < 6; i++ {
+		ps = append(ps, g.NewPlayer(guuid.New()))
+	}
+	for _, p := range ps {
+		if p.Team == 2 {
+			g.DeletePlayer(p.UUID)
+		}
+	}
+	g.Step(1 / float64(g.TickRate()))
+	if g.Status != Countdown {
+		t.Fatalf("status is %s, want %s", g.Status, Countdown)
+	}
+	// uneven teams wait for the match
+	if want := []int{1, 1, 1}; !reflect.DeepEqual(teamsOf(g), want) {
+		t.Errorf("teams are %v in the countdown, want %v", teamsOf(g), want)
+	}
+	for g.Status != Playing {
+		g.Step(1 / float64(g.TickRate()))
+	}
+	if want := []int{1, 1, 2}; !reflect.DeepEqual(teamsOf(g), want) {
+		t.Errorf("teams are %v, want %v", teamsOf(g), want)
+	}
+}
+
+func TestTeamKill(t *testing.T) {
+	tests := []struct {
+		friendlyFire bool
+		life         float64
+		score        int
+	}{
+		{false, MaxLife, 0},
+		{true, 0, -1},
+	}
+	for _, tt := range tests {
+		g := teamGame(2, tt.friendlyFire)
+		a := g.NewPlayer(guuid.New())
+		g.NewPlayer(guuid.New())
+		mate := g.NewPlayer(guuid.New())
+		if !g.Allies(a, mate) || g.CanHurt(a, mate) != tt.friendlyFire || !g.CanHurt(nil, mate) {
+			t.Errorf("friendly fire %v: teams %v, can hurt %v", tt.friendlyFire, teamsOf(g), g.CanHurt(a, mate))
+		}
+		mate.Invulnerable = 0
+		g.damage(mate, a, MaxLife)
+		if mate.Life != tt.life || a.Score != tt.score || a.Kills != 0 || g.Team(a.Team).Score != 0 {
+			t.Errorf("friendly fire %v: teammate has %v life, killer %d points and %d kills, team %d points, want %v life and %d points",
+				tt.friendlyFire, mate.Life, a.Score, a.Kills, g.Team(a.Team).Score, tt.life, tt.score)
+		}
+	}
+}
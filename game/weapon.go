@@ -0,0 +1,230 @@
+package game
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+	"github.com/pkg/errors"
+)
+
+// Weapon is the definition of a weapon. Reload is the time between shots,
+// Count projectiles are fired per shot, fanned out over Spread radians.
+// Projectiles fly at Speed for Lifetime seconds, with Speed 0 they are
+// mines that stay where they were dropped. Pierce projectiles fly through
+// ships, Blast projectiles explode and hurt every ship within the blast
+// radius. Projectiles fly Range at most, 0 is unlimited. Homing
+// projectiles lock on to enemies within SeekRadius and turn towards them by
+// up to TurnRate radians per second. A pickup of the weapon gives Ammo
+// shots, 0 is unlimited.
+type Weapon struct {
+	Name       string  `json:"name"`
+	Reload     float64 `json:"reload"`
+	Speed      float64 `json:"speed"`
+	Lifetime   float64 `json:"lifetime"`
+	Damage     float64 `json:"damage"`
+	Count      int     `json:"count"`
+	Spread     float64 `json:"spread"`
+	Radius     float64 `json:"radius"`
+	Pierce     bool    `json:"pierce"`
+	Blast      float64 `json:"blast"`
+	Range      float64 `json:"range"`
+	TurnRate   float64 `json:"turnRate"`
+	SeekRadius float64 `json:"seekRadius"`
+	Ammo       int     `json:"ammo"`
+}
+
+// WeaponRegistry holds the weapons of a game. Every player starts with
+// the Default weapon, the others are found in pickups.
+type WeaponRegistry struct {
+	Default string    `json:"default"`
+	Weapons []*Weapon `json:"weapons"`
+}
+
+// Slot is a weapon a player carries and the shots left, 0 is unlimited.
+type Slot struct {
+	Weapon *Weapon
+	Ammo   int
+}
+
+func DefaultWeapons() *WeaponRegistry {
+	return &WeaponRegistry{
+		Default: "blaster",
+		Weapons: []*Weapon{
+			{Name: "blaster", Reload: ReloadDelay, Speed: BulletSpeed, Lifetime: BulletLifetime, Damage: 1, Count: 1, Radius: 1.5},
+			{Name: "spread", Reload: 0.6, Speed: 220, Lifetime: 1.5, Damage: 0.8, Count: 5, Spread: 0.6, Radius: 1.5, Ammo: 20},
+			{Name: "railgun", Reload: 1.5, Speed: 2400, Lifetime: 0.5, Damage: 4, Count: 1, Radius: 1, Pierce: true, Range: 900, Ammo: 5},
+			{Name: "pig", Reload: 1, Speed: 140, Lifetime: 6, Damage: 3, Count: 1, Radius: 4, Range: 700, TurnRate: 2.5, SeekRadius: 300, Ammo: 6},
+			{Name: "mine", Reload: 1, Speed: 0, Lifetime: 20, Damage: 5, Count: 1, Radius: 4, Blast: 40, Ammo: 3},
+			{Name: "bomb", Reload: 1.2, Speed: 150, Lifetime: 1.2, Damage: 4, Count: 1, Radius: 3, Blast: 60, Ammo: 4},
+		},
+	}
+}
+
+// LoadWeapons reads and validates a JSON weapon registry.
+func LoadWeapons(path string) (*WeaponRegistry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading weapons")
+	}
+	var r WeaponRegistry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.Wrapf(err, "error parsing weapons %s", path)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid weapons %s", path)
+	}
+	return &r, nil
+}
+
+func (r *WeaponRegistry) Validate() error {
+	seen := map[string]bool{}
+	for i, w := range r.Weapons {
+		switch {
+		case w.Name == "":
+			return errors.Errorf("weapon %d has no name", i)
+		case seen[w.Name]:
+			return errors.Errorf("weapon %s is defined twice", w.Name)
+		case w.Reload <= 0:
+			return errors.Errorf("weapon %s has reload %v", w.Name, w.Reload)
+		case w.Speed < 0 || w.Lifetime <= 0 || w.Damage < 0:
+			return errors.Errorf("weapon %s needs a speed, lifetime and damage", w.Name)
+		case w.Count < 1:
+			return errors.Errorf("weapon %s fires %d projectiles", w.Name, w.Count)
+		case w.Radius < 0 || w.Blast < 0 || w.Range < 0 || w.Ammo < 0:
+			return errors.Errorf("weapon %s has a negative radius, blast, range or ammo", w.Name)
+		case w.TurnRate < 0 || (w.TurnRate > 0 && w.SeekRadius <= 0):
+			return errors.Errorf("weapon %s needs a seek radius to home in", w.Name)
+		}
+		seen[w.Name] = true
+	}
+	if !seen[r.Default] {
+		return errors.Errorf("default weapon %q is not defined", r.Default)
+	}
+	return nil
+}
+
+// Get returns the weapon called name or nil.
+func (r *WeaponRegistry) Get(name string) *Weapon {
+	for _, w := range r.Weapons {
+		if w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// random returns a weapon other than the default one for pickups.
+func (r *WeaponRegistry) random() *Weapon {
+	var extra []*Weapon
+	for _, w := range r.Weapons {
+		if w.Name != r.Default {
+			extra = append(extra, w)
+		}
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return extra[rand.Intn(len(extra))]
+}
+
+func (g *Game) SetWeapons(r *WeaponRegistry) {
+	if r == nil {
+		r = DefaultWeapons()
+	}
+	g.weapons = r
+}
+
+// Weapon returns the weapon p has selected.
+func (p *Player) Weapon() *Weapon {
+	if p.Slot >= len(p.Inventory) {
+		return nil
+	}
+	return p.Inventory[p.Slot].Weapon
+}
+
+// resetInventory leaves p with the default weapon only.
+func (g *Game) resetInventory(p *Player) {
+	p.Inventory = []Slot{{Weapon: g.weapons.Get(g.weapons.Default)}}
+	p.Slot = 0
+}
+
+// giveWeapon adds w to the inventory of p, or its ammo if p has it
+// already, and selects it.
+func (g *Game) giveWeapon(p *Player, w *Weapon) {
+	for i := range p.Inventory {
+		if p.Inventory[i].Weapon == w {
+			if p.Inventory[i].Ammo > 0 {
+				p.Inventory[i].Ammo += w.Ammo
+			}
+			p.Slot = i
+			return
+		}
+	}
+	p.Inventory = append(p.Inventory, Slot{Weapon: w, Ammo: w.Ammo})
+	p.Slot = len(p.Inventory) - 1
+}
+
+// selectWeapon switches p to the weapon called name if it carries it.
+func (p *Player) selectWeapon(name string) {
+	for i, s := range p.Inventory {
+		if s.Weapon.Name == name {
+			p.Slot = i
+			return
+		}
+	}
+}
+
+// fire shoots the selected weapon of p and uses up one shot of it.
+func (g *Game) fire(p *Player) {
+	w := p.Weapon()
+	if w == nil {
+		return
+	}
+	damage, reload := w.Damage*p.Power, w.Reload
+	if p.Effects.Damage > 0 {
+		damage *= DamageBoostFactor
+	}
+	if p.Effects.RapidFire > 0 {
+		reload *= RapidFireFactor
+	}
+	for i := 0; i < w.Count; i++ {
+		rot := p.Rotation
+		if w.Count > 1 {
+			rot += RotationDegree(w.Spread * (float64(i)/float64(w.Count-1) - 0.5))
+		}
+		g.AddBullet(p.X, p.Y, p.UUID, rot, w, damage, p.Velocity)
+	}
+	p.ReloadTime = reload
+	// shooting ends the spawn protection
+	p.Invulnerable = 0
+
+	s := &p.Inventory[p.Slot]
+	if s.Ammo == 0 {
+		return
+	}
+	s.Ammo--
+	if s.Ammo == 0 {
+		p.Inventory = append(p.Inventory[:p.Slot], p.Inventory[p.Slot+1:]...)
+		p.Slot = 0
+		// so selecting it after the next pickup switches again
+		p.SelectWeapon = ""
+	}
+}
+
+// explode hurts every ship but the owner's within the blast radius of b
+// around at, less the farther away it is. skip already took a direct hit.
+func (g *Game) explode(b *Bullet, at pixel.Vec, skip *Player) {
+	owner := g.playerMap[b.Owner]
+	for _, p := range g.PlayersNear(at, b.Blast) {
+		if p == owner || p == skip {
+			continue
+		}
+		d := at.To(pixel.V(p.X, p.Y)).Len()
+		if d >= b.Blast {
+			continue
+		}
+		g.damage(p, owner, b.Damage*(1-d/b.Blast))
+	}
+}
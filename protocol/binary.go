@@ -0,0 +1,860 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Binary frames are sent as websocket binary messages. A frame starts with
+// the header, followed by the snapshot: its kind, the tick and, for deltas,
+// the tick of the baseline. Ids and counts are varints, positions, speeds
+// and life are fixed point.
+//
+// Every player, bullet and pickup of the snapshot is listed with a bit mask of the
+// fields that follow; fields missing from a delta are unchanged from the
+// baseline. Entities of the baseline that aren't listed are gone.
+const frameFull byte = 1
+const frameDelta byte = 2
+
+const positionScale = 8
+const lifeScale = 16
+const timeScale = 16
+const rotationSteps = 1 << 16
+
+const (
+	playerX = 1 << iota
+	playerY
+	playerVX
+	playerVY
+	playerRotation
+	playerLife
+	playerScore
+	playerStatus
+	playerName
+	playerRespawnTime
+	playerInvulnerable
+	playerStats
+	playerEffects
+	playerWeapon
+	playerBot
+	playerTeam
+	// playerAll has every field bit set
+	playerAll = 1<<iota - 1
+)
+
+const (
+	bulletX = 1 << iota
+	bulletY
+	bulletVX
+	bulletVY
+	bulletRotation
+	bulletOwner
+	bulletWeapon
+	bulletAll = 1<<iota - 1
+)
+
+const (
+	pickupX = 1 << iota
+	pickupY
+	pickupKind
+	pickupWeapon
+	pickupAll = 1<<iota - 1
+)
+
+var ErrMissingBaseline = errors.New("baseline snapshot not available")
+
+// HistorySize is the number of snapshots kept to encode or decode deltas.
+// Acks older than that fall back to full snapshots.
+const HistorySize = 64
+
+// History keeps the most recent snapshots by tick.
+type History struct {
+	snaps [HistorySize]*Snapshot
+}
+
+func (h *History) Add(s *Snapshot) {
+	h.snaps[s.Tick%HistorySize] = s
+}
+
+// Get returns the snapshot of tick, or nil if it isn't kept anymore.
+func (h *History) Get(tick uint64) *Snapshot {
+	s := h.snaps[tick%HistorySize]
+	if s == nil || s.Tick != tick {
+		return nil
+	}
+	return s
+}
+
+func quantize(v float64, scale float64) int64 {
+	return int64(math.Round(v * scale))
+}
+
+func dequantize(v int64, scale float64) float64 {
+	return float64(v) / scale
+}
+
+func quantizeRotation(r float64) uint64 {
+	steps := int64(math.Round(r / (2 * math.Pi) * rotationSteps))
+	steps %= rotationSteps
+	if steps < 0 {
+		steps += rotationSteps
+	}
+	return uint64(steps)
+}
+
+func dequantizeRotation(v uint64) float64 {
+	return float64(v) / rotationSteps * 2 * math.Pi
+}
+
+// quantPlayer, quantBullet and quantPickup hold the values as they go over the wire,
+// deltas compare these so encoder and decoder agree on what changed.
+type quantPlayer struct {
+	x, y, vx, vy int64
+	rotation     uint64
+	life         int64
+	score        int64
+	status, name string
+	respawnTime  int64
+	invulnerable int64
+	stats        [5]int64
+	effects      [4]int64
+	weapon       string
+	ammo         int64
+	// weapons is the inventory joined, so it compares like the rest
+	weapons string
+	bot     bool
+	team    int64
+}
+
+func newQuantPlayer(p *Player) quantPlayer {
+	return quantPlayer{
+		x:            quantize(p.X, positionScale),
+		y:            quantize(p.Y, positionScale),
+		vx:           quantize(p.VX, positionScale),
+		vy:           quantize(p.VY, positionScale),
+		rotation:     quantizeRotation(p.Rotation),
+		life:         quantize(p.Life, lifeScale),
+		score:        int64(p.Score),
+		status:       p.Status,
+		name:         p.Name,
+		respawnTime:  quantize(p.RespawnTime, timeScale),
+		invulnerable: quantize(p.Invulnerable, timeScale),
+		stats: [5]int64{
+			int64(p.Hits),
+			quantize(p.DamageDealt, lifeScale),
+			int64(p.Kills),
+			int64(p.Deaths),
+			int64(p.Assists),
+		},
+		effects: [4]int64{
+			quantize(p.RapidFire, timeScale),
+			quantize(p.DamageBoost, timeScale),
+			quantize(p.Shield, timeScale),
+			quantize(p.SpeedBoost, timeScale),
+		},
+		weapon:  p.Weapon,
+		ammo:    int64(p.Ammo),
+		weapons: strings.Join(p.Weapons, "\n"),
+		bot:     p.Bot,
+		team:    int64(p.Team),
+	}
+}
+
+type quantBullet struct {
+	x, y, vx, vy int64
+	rotation     uint64
+	owner        uint32
+	weapon       string
+}
+
+func newQuantBullet(b *Bullet) quantBullet {
+	return quantBullet{
+		x:        quantize(b.X, positionScale),
+		y:        quantize(b.Y, positionScale),
+		vx:       quantize(b.VX, positionScale),
+		vy:       quantize(b.VY, positionScale),
+		rotation: quantizeRotation(b.Rotation),
+		owner:    b.Owner,
+		weapon:   b.Weapon,
+	}
+}
+
+type quantPickup struct {
+	x, y         int64
+	kind, weapon string
+}
+
+func newQuantPickup(k *Pickup) quantPickup {
+	return quantPickup{
+		x:      quantize(k.X, positionScale),
+		y:      quantize(k.Y, positionScale),
+		kind:   k.Kind,
+		weapon: k.Weapon,
+	}
+}
+
+type encoder struct {
+	buf []byte
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) byte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.tmp[:], v)
+	e.buf = append(e.buf, e.tmp[:n]...)
+}
+
+func (e *encoder) varint(v int64) {
+	n := binary.PutVarint(e.tmp[:], v)
+	e.buf = append(e.buf, e.tmp[:n]...)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// BinaryFrame prepends the header h to a snapshot encoded by EncodeBinary.
+func BinaryFrame(h Header, snapshot []byte) []byte {
+	e := &encoder{buf: make([]byte, 0, 3*binary.MaxVarintLen32+len(snapshot))}
+	e.uvarint(uint64(h.You))
+	e.uvarint(uint64(h.Ack))
+	e.uvarint(uint64(h.Epoch))
+	e.buf = append(e.buf, snapshot...)
+	return e.buf
+}
+
+// EncodeBinary encodes s as a delta against base, or in full if base is
+// nil.
+func EncodeBinary(s *Snapshot, base *Snapshot) []byte {
+	e := &encoder{buf: make([]byte, 0, 64+len(s.Players)*24+len(s.Bullets)*12)}
+	if base == nil {
+		e.byte(frameFull)
+		e.uvarint(s.Tick)
+	} else {
+		e.byte(frameDelta)
+		e.uvarint(s.Tick)
+		e.uvarint(base.Tick)
+	}
+	e.string(s.Status)
+	e.varint(quantize(s.StatusTime, timeScale))
+	e.varint(quantize(s.Bounds.X, positionScale))
+	e.varint(quantize(s.Bounds.Y, positionScale))
+	e.varint(quantize(s.Bounds.Width, positionScale))
+	e.varint(quantize(s.Bounds.Height, positionScale))
+	e.string(s.Bounds.Edge)
+	e.string(s.Mode)
+	// teams and flags are few, they go in full like the bounds
+	e.uvarint(uint64(len(s.Teams)))
+	for _, t := range s.Teams {
+		e.uvarint(uint64(t.ID))
+		e.string(t.Name)
+		e.string(t.Color)
+		e.varint(int64(t.Score))
+	}
+	e.uvarint(uint64(len(s.Flags)))
+	for _, f := range s.Flags {
+		e.uvarint(uint64(f.ID))
+		e.uvarint(uint64(f.Team))
+		e.varint(quantize(f.X, positionScale))
+		e.varint(quantize(f.Y, positionScale))
+		e.varint(quantize(f.BaseX, positionScale))
+		e.varint(quantize(f.BaseY, positionScale))
+		e.uvarint(uint64(f.Carrier))
+	}
+	if z := s.Zone; z != nil {
+		e.byte(1)
+		e.varint(quantize(z.X, positionScale))
+		e.varint(quantize(z.Y, positionScale))
+		e.varint(quantize(z.Radius, positionScale))
+	} else {
+		e.byte(0)
+	}
+
+	basePlayers := map[uint32]*Player{}
+	baseBullets := map[uint32]*Bullet{}
+	basePickups := map[uint32]*Pickup{}
+	if base != nil {
+		for i := range base.Players {
+			basePlayers[base.Players[i].ID] = &base.Players[i]
+		}
+		for i := range base.Bullets {
+			baseBullets[base.Bullets[i].ID] = &base.Bullets[i]
+		}
+		for i := range base.Pickups {
+			basePickups[base.Pickups[i].ID] = &base.Pickups[i]
+		}
+	}
+
+	e.uvarint(uint64(len(s.Players)))
+	for i := range s.Players {
+		p := &s.Players[i]
+		q := newQuantPlayer(p)
+		mask := uint64(playerAll)
+		if bp, ok := basePlayers[p.ID]; ok {
+			mask = 0
+			bq := newQuantPlayer(bp)
+			if q.x != bq.x {
+				mask |= playerX
+			}
+			if q.y != bq.y {
+				mask |= playerY
+			}
+			if q.vx != bq.vx {
+				mask |= playerVX
+			}
+			if q.vy != bq.vy {
+				mask |= playerVY
+			}
+			if q.rotation != bq.rotation {
+				mask |= playerRotation
+			}
+			if q.life != bq.life {
+				mask |= playerLife
+			}
+			if q.score != bq.score {
+				mask |= playerScore
+			}
+			if q.status != bq.status {
+				mask |= playerStatus
+			}
+			if q.name != bq.name {
+				mask |= playerName
+			}
+			if q.respawnTime != bq.respawnTime {
+				mask |= playerRespawnTime
+			}
+			if q.invulnerable != bq.invulnerable {
+				mask |= playerInvulnerable
+			}
+			if q.stats != bq.stats {
+				mask |= playerStats
+			}
+			if q.effects != bq.effects {
+				mask |= playerEffects
+			}
+			if q.weapon != bq.weapon || q.ammo != bq.ammo || q.weapons != bq.weapons {
+				mask |= playerWeapon
+			}
+			if q.bot != bq.bot {
+				mask |= playerBot
+			}
+			if q.team != bq.team {
+				mask |= playerTeam
+			}
+		}
+		e.uvarint(uint64(p.ID))
+		e.uvarint(mask)
+		if mask&playerX != 0 {
+			e.varint(q.x)
+		}
+		if mask&playerY != 0 {
+			e.varint(q.y)
+		}
+		if mask&playerVX != 0 {
+			e.varint(q.vx)
+		}
+		if mask&playerVY != 0 {
+			e.varint(q.vy)
+		}
+		if mask&playerRotation != 0 {
+			e.uvarint(q.rotation)
+		}
+		if mask&playerLife != 0 {
+			e.varint(q.life)
+		}
+		if mask&playerScore != 0 {
+			e.varint(q.score)
+		}
+		if mask&playerStatus != 0 {
+			e.string(q.status)
+		}
+		if mask&playerName != 0 {
+			e.string(q.name)
+		}
+		if mask&playerRespawnTime != 0 {
+			e.varint(q.respawnTime)
+		}
+		if mask&playerInvulnerable != 0 {
+			e.varint(q.invulnerable)
+		}
+		if mask&playerStats != 0 {
+			for _, v := range q.stats {
+				e.varint(v)
+			}
+		}
+		if mask&playerEffects != 0 {
+			for _, v := range q.effects {
+				e.varint(v)
+			}
+		}
+		if mask&playerWeapon != 0 {
+			e.string(q.weapon)
+			e.varint(q.ammo)
+			e.uvarint(uint64(len(p.Weapons)))
+			for _, w := range p.Weapons {
+				e.string(w)
+			}
+		}
+		if mask&playerBot != 0 {
+			var bot byte
+			if q.bot {
+				bot = 1
+			}
+			e.byte(bot)
+		}
+		if mask&playerTeam != 0 {
+			e.varint(q.team)
+		}
+	}
+
+	e.uvarint(uint64(len(s.Bullets)))
+	for i := range s.Bullets {
+		b := &s.Bullets[i]
+		q := newQuantBullet(b)
+		mask := uint64(bulletAll)
+		if bb, ok := baseBullets[b.ID]; ok {
+			mask = 0
+			bq := newQuantBullet(bb)
+			if q.x != bq.x {
+				mask |= bulletX
+			}
+			if q.y != bq.y {
+				mask |= bulletY
+			}
+			if q.vx != bq.vx {
+				mask |= bulletVX
+			}
+			if q.vy != bq.vy {
+				mask |= bulletVY
+			}
+			if q.rotation != bq.rotation {
+				mask |= bulletRotation
+			}
+			if q.owner != bq.owner {
+				mask |= bulletOwner
+			}
+			if q.weapon != bq.weapon {
+				mask |= bulletWeapon
+			}
+		}
+		e.uvarint(uint64(b.ID))
+		e.uvarint(mask)
+		if mask&bulletX != 0 {
+			e.varint(q.x)
+		}
+		if mask&bulletY != 0 {
+			e.varint(q.y)
+		}
+		if mask&bulletVX != 0 {
+			e.varint(q.vx)
+		}
+		if mask&bulletVY != 0 {
+			e.varint(q.vy)
+		}
+		if mask&bulletRotation != 0 {
+			e.uvarint(q.rotation)
+		}
+		if mask&bulletOwner != 0 {
+			e.uvarint(uint64(q.owner))
+		}
+		if mask&bulletWeapon != 0 {
+			e.string(q.weapon)
+		}
+	}
+
+	e.uvarint(uint64(len(s.Pickups)))
+	for i := range s.Pickups {
+		k := &s.Pickups[i]
+		q := newQuantPickup(k)
+		mask := uint64(pickupAll)
+		if bk, ok := basePickups[k.ID]; ok {
+			mask = 0
+			bq := newQuantPickup(bk)
+			if q.x != bq.x {
+				mask |= pickupX
+			}
+			if q.y != bq.y {
+				mask |= pickupY
+			}
+			if q.kind != bq.kind {
+				mask |= pickupKind
+			}
+			if q.weapon != bq.weapon {
+				mask |= pickupWeapon
+			}
+		}
+		e.uvarint(uint64(k.ID))
+		e.uvarint(mask)
+		if mask&pickupX != 0 {
+			e.varint(q.x)
+		}
+		if mask&pickupY != 0 {
+			e.varint(q.y)
+		}
+		if mask&pickupKind != 0 {
+			e.string(q.kind)
+		}
+		if mask&pickupWeapon != 0 {
+			e.string(q.weapon)
+		}
+	}
+
+	e.uvarint(uint64(len(s.Events)))
+	for _, ev := range s.Events {
+		e.string(ev.Type)
+		e.uvarint(uint64(ev.Player))
+		e.uvarint(uint64(ev.Other))
+		e.varint(quantize(ev.Value, lifeScale))
+		e.string(ev.Status)
+		e.string(ev.Pickup)
+		e.varint(int64(ev.Team))
+	}
+	return e.buf
+}
+
+// decoder reads what encoder wrote. The first error sticks and turns all
+// further reads into zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail(msg string) {
+	if d.err == nil {
+		d.err = errors.Wrap(ErrMalformed, msg)
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) == 0 {
+		d.fail("unexpected end of frame")
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail("bad uvarint")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail("bad varint")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.buf)) < n {
+		d.fail("string out of range")
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
+
+// count reads a list length and rejects lengths that can't possibly fit
+// into the rest of the frame.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail("count out of range")
+		return 0
+	}
+	return int(n)
+}
+
+// BinaryDecoder decodes binary snapshots of one connection. It keeps the
+// snapshots it decoded as baselines for later deltas, until the epoch
+// changes.
+type BinaryDecoder struct {
+	history History
+	epoch   uint32
+}
+
+func NewBinaryDecoder() *BinaryDecoder {
+	return &BinaryDecoder{}
+}
+
+func (bd *BinaryDecoder) Decode(data []byte) (*Frame, error) {
+	d := &decoder{buf: data}
+	f := &Frame{Envelope: Envelope{Version: Version, Type: TypeSnapshot}}
+	f.You = uint32(d.uvarint())
+	f.Ack = uint32(d.uvarint())
+	f.Epoch = uint32(d.uvarint())
+	if f.Epoch != bd.epoch {
+		// a new room, its ticks have nothing to do with the old ones
+		bd.history = History{}
+		bd.epoch = f.Epoch
+	}
+	s := &f.World
+
+	var base *Snapshot
+	switch d.byte() {
+	case frameFull:
+		s.Tick = d.uvarint()
+	case frameDelta:
+		s.Tick = d.uvarint()
+		baseTick := d.uvarint()
+		base = bd.history.Get(baseTick)
+		if d.err == nil && base == nil {
+			return nil, errors.Wrapf(ErrMissingBaseline, "tick %d", baseTick)
+		}
+	default:
+		d.fail("unknown frame kind")
+	}
+	s.Status = d.string()
+	s.StatusTime = dequantize(d.varint(), timeScale)
+	s.Bounds.X = dequantize(d.varint(), positionScale)
+	s.Bounds.Y = dequantize(d.varint(), positionScale)
+	s.Bounds.Width = dequantize(d.varint(), positionScale)
+	s.Bounds.Height = dequantize(d.varint(), positionScale)
+	s.Bounds.Edge = d.string()
+	s.Mode = d.string()
+	n := d.count()
+	for i := 0; i < n; i++ {
+		s.Teams = append(s.Teams, Team{
+			ID:    int(d.uvarint()),
+			Name:  d.string(),
+			Color: d.string(),
+			Score: int(d.varint()),
+		})
+	}
+	n = d.count()
+	for i := 0; i < n; i++ {
+		s.Flags = append(s.Flags, Flag{
+			ID:      uint32(d.uvarint()),
+			Team:    int(d.uvarint()),
+			X:       dequantize(d.varint(), positionScale),
+			Y:       dequantize(d.varint(), positionScale),
+			BaseX:   dequantize(d.varint(), positionScale),
+			BaseY:   dequantize(d.varint(), positionScale),
+			Carrier: uint32(d.uvarint()),
+		})
+	}
+	if d.byte() == 1 {
+		s.Zone = &Zone{
+			X:      dequantize(d.varint(), positionScale),
+			Y:      dequantize(d.varint(), positionScale),
+			Radius: dequantize(d.varint(), positionScale),
+		}
+	}
+
+	basePlayers := map[uint32]*Player{}
+	baseBullets := map[uint32]*Bullet{}
+	basePickups := map[uint32]*Pickup{}
+	if base != nil {
+		for i := range base.Players {
+			basePlayers[base.Players[i].ID] = &base.Players[i]
+		}
+		for i := range base.Bullets {
+			baseBullets[base.Bullets[i].ID] = &base.Bullets[i]
+		}
+		for i := range base.Pickups {
+			basePickups[base.Pickups[i].ID] = &base.Pickups[i]
+		}
+	}
+
+	n = d.count()
+	s.Players = make([]Player, 0, n)
+	for i := 0; i < n; i++ {
+		var p Player
+		id := uint32(d.uvarint())
+		if bp, ok := basePlayers[id]; ok {
+			p = *bp
+		}
+		p.ID = id
+		mask := d.uvarint()
+		if mask&playerX != 0 {
+			p.X = dequantize(d.varint(), positionScale)
+		}
+		if mask&playerY != 0 {
+			p.Y = dequantize(d.varint(), positionScale)
+		}
+		if mask&playerVX != 0 {
+			p.VX = dequantize(d.varint(), positionScale)
+		}
+		if mask&playerVY != 0 {
+			p.VY = dequantize(d.varint(), positionScale)
+		}
+		if mask&playerRotation != 0 {
+			p.Rotation = dequantizeRotation(d.uvarint())
+		}
+		if mask&playerLife != 0 {
+			p.Life = dequantize(d.varint(), lifeScale)
+		}
+		if mask&playerScore != 0 {
+			p.Score = int(d.varint())
+		}
+		if mask&playerStatus != 0 {
+			p.Status = d.string()
+		}
+		if mask&playerName != 0 {
+			p.Name = d.string()
+		}
+		if mask&playerRespawnTime != 0 {
+			p.RespawnTime = dequantize(d.varint(), timeScale)
+		}
+		if mask&playerInvulnerable != 0 {
+			p.Invulnerable = dequantize(d.varint(), timeScale)
+		}
+		if mask&playerStats != 0 {
+			p.Hits = int(d.varint())
+			p.DamageDealt = dequantize(d.varint(), lifeScale)
+			p.Kills = int(d.varint())
+			p.Deaths = int(d.varint())
+			p.Assists = int(d.varint())
+		}
+		if mask&playerEffects != 0 {
+			p.RapidFire = dequantize(d.varint(), timeScale)
+			p.DamageBoost = dequantize(d.varint(), timeScale)
+			p.Shield = dequantize(d.varint(), timeScale)
+			p.SpeedBoost = dequantize(d.varint(), timeScale)
+		}
+		if mask&playerWeapon != 0 {
+			p.Weapon = d.string()
+			p.Ammo = int(d.varint())
+			n := d.count()
+			p.Weapons = make([]string, 0, n)
+			for j := 0; j < n; j++ {
+				p.Weapons = append(p.Weapons, d.string())
+			}
+		}
+		if mask&playerBot != 0 {
+			p.Bot = d.byte() != 0
+		}
+		if mask&playerTeam != 0 {
+			p.Team = int(d.varint())
+		}
+		s.Players = append(s.Players, p)
+	}
+
+	n = d.count()
+	s.Bullets = make([]Bullet, 0, n)
+	for i := 0; i < n; i++ {
+		var b Bullet
+		id := uint32(d.uvarint())
+		if bb, ok := baseBullets[id]; ok {
+			b = *bb
+		}
+		b.ID = id
+		mask := d.uvarint()
+		if mask&bulletX != 0 {
+			b.X = dequantize(d.varint(), positionScale)
+		}
+		if mask&bulletY != 0 {
+			b.Y = dequantize(d.varint(), positionScale)
+		}
+		if mask&bulletVX != 0 {
+			b.VX = dequantize(d.varint(), positionScale)
+		}
+		if mask&bulletVY != 0 {
+			b.VY = dequantize(d.varint(), positionScale)
+		}
+		if mask&bulletRotation != 0 {
+			b.Rotation = dequantizeRotation(d.uvarint())
+		}
+		if mask&bulletOwner != 0 {
+			b.Owner = uint32(d.uvarint())
+		}
+		if mask&bulletWeapon != 0 {
+			b.Weapon = d.string()
+		}
+		s.Bullets = append(s.Bullets, b)
+	}
+
+	n = d.count()
+	s.Pickups = make([]Pickup, 0, n)
+	for i := 0; i < n; i++ {
+		var k Pickup
+		id := uint32(d.uvarint())
+		if bk, ok := basePickups[id]; ok {
+			k = *bk
+		}
+		k.ID = id
+		mask := d.uvarint()
+		if mask&pickupX != 0 {
+			k.X = dequantize(d.varint(), positionScale)
+		}
+		if mask&pickupY != 0 {
+			k.Y = dequantize(d.varint(), positionScale)
+		}
+		if mask&pickupKind != 0 {
+			k.Kind = d.string()
+		}
+		if mask&pickupWeapon != 0 {
+			k.Weapon = d.string()
+		}
+		s.Pickups = append(s.Pickups, k)
+	}
+
+	n = d.count()
+	for i := 0; i < n; i++ {
+		s.Events = append(s.Events, Event{
+			Type:   d.string(),
+			Player: uint32(d.uvarint()),
+			Other:  uint32(d.uvarint()),
+			Value:  dequantize(d.varint(), lifeScale),
+			Status: d.string(),
+			Pickup: d.string(),
+			Team:   int(d.varint()),
+		})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	// the caller may change its frame, the baseline has to stay as sent
+	bd.history.Add(s.clone())
+	return f, nil
+}
+
+// clone returns a copy of s that shares no slices with it.
+func (s *Snapshot) clone() *Snapshot {
+	c := *s
+	c.Teams = append([]Team(nil), s.Teams...)
+	c.Flags = append([]Flag(nil), s.Flags...)
+	if s.Zone != nil {
+		z := *s.Zone
+		c.Zone = &z
+	}
+	c.Players = append([]Player{}, s.Players...)
+	for i := range c.Players {
+		c.Players[i].Weapons = append([]string{}, s.Players[i].Weapons...)
+	}
+	c.Bullets = append([]Bullet{}, s.Bullets...)
+	c.Pickups = append([]Pickup{}, s.Pickups...)
+	c.Events = append([]Event(nil), s.Events...)
+	return &c
+}
@@ -0,0 +1,213 @@
+package protocol
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// sample returns a snapshot whose values survive the quantization
+// unchanged, so decoded snapshots compare equal.
+func sample() Snapshot {
+	return Snapshot{
+		Tick:       10,
+		Status:     StatusPlaying,
+		StatusTime: 120.5,
+		Mode:       "ctf",
+		Bounds:     Bounds{X: 8, Y: 16, Width: 1024, Height: 768, Edge: "wall"},
+		Teams:      []Team{{ID: 1, Name: "red", Color: "#ff0000", Score: 3}, {ID: 2, Name: "blue", Color: "#0000ff"}},
+		Flags:      []Flag{{ID: 9, Team: 1, X: 64, Y: 384, BaseX: 64, BaseY: 384, Carrier: 2}},
+		Zone:       &Zone{X: 512, Y: 384.5, Radius: 80},
+		Players: []Player{
+			{ID: 1, Name: "porky", X: 100.125, Y: 200, VX: -30.5, VY: 12, Rotation: math.Pi, Life: 7.5,
+				Score: 4, Status: "Ready", Hits: 3, DamageDealt: 4.25, Kills: 2, Deaths: 1, Assists: 1,
+				Weapon: "spread", Ammo: 12, Weapons: []string{"blaster", "spread"}, Team: 1},
+			{ID: 2, Name: "bot 1", X: 300, Y: 50, Life: 0, Status: "Died", RespawnTime: 2.5,
+				Shield: 1.5, Weapon: "blaster", Weapons: []string{"blaster"}, Bot: true, Team: 2},
+		},
+		Bullets: []Bullet{
+			{ID: 20, Owner: 1, X: 110, Y: 210, VX: 300, VY: -300, Rotation: math.Pi / 2, Weapon: "blaster"},
+			{ID: 21, Owner: 2, X: 10, Y: 20, Weapon: "mine"},
+		},
+		Pickups: []Pickup{
+			{ID: 30, Kind: "health", X: 512, Y: 300},
+			{ID: 31, Kind: "weapon", Weapon: "railgun", X: 120, Y: 384},
+		},
+		Events: []Event{{Type: "hit", Player: 2, Other: 1, Value: 1.5}, {Type: "flag_taken", Player: 2, Team: 1}},
+	}
+}
+
+func roundTrip(t *testing.T, d *BinaryDecoder, s, base *Snapshot) *Frame {
+	t.Helper()
+	f, err := d.Decode(BinaryFrame(Header{You: 1, Ack: 7}, EncodeBinary(s, base)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.You != 1 || f.Ack != 7 {
+		t.Errorf("header is %+v, want you 1 and ack 7", f.Header)
+	}
+	return f
+}
+
+func TestBinaryFull(t *testing.T) {
+	s := sample()
+	f := roundTrip(t, NewBinaryDecoder(), &s, nil)
+	if !reflect.DeepEqual(f.World, s) {
+		t.Errorf("decoded\n%+v\nwant\n%+v", f.World, s)
+	}
+}
+
+func TestBinaryDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *Snapshot)
+	}{
+		{"unchanged", func(s *Snapshot) {}},
+		{"player fields", func(s *Snapshot) {
+			p := &s.Players[0]
+			p.X, p.Life, p.Status = 101, 6, "Respawn"
+			p.Weapon, p.Ammo, p.Weapons = "blaster", 0, []string{"blaster"}
+			s.Players[1].Bot = false
+		}},
+		{"player added", func(s *Snapshot) {
+			s.Players = append(s.Players, Player{ID: 3, Name: "new", X: 1, Y: 2, Life: 10, Status: "Respawn", Weapons: []string{}})
+		}},
+		{"player removed", func(s *Snapshot) {
+			s.Players = s.Players[1:]
+		}},
+		{"bullet moved", func(s *Snapshot) {
+			s.Bullets[0].X, s.Bullets[0].Rotation = 115, 0
+		}},
+		{"bullet added and removed", func(s *Snapshot) {
+			s.Bullets = append(s.Bullets[:1], Bullet{ID: 22, Owner: 1, X: 5, Y: 5, VX: 1, Weapon: "pig"})
+		}},
+		{"pickup changed", func(s *Snapshot) {
+			s.Pickups[1].Weapon = "bomb"
+		}},
+		{"pickup added and removed", func(s *Snapshot) {
+			s.Pickups = []Pickup{s.Pickups[1], {ID: 32, Kind: "shield", X: 1, Y: 1}}
+		}},
+		{"everything gone", func(s *Snapshot) {
+			s.Teams, s.Flags, s.Zone, s.Events = nil, nil, nil, nil
+			s.Players, s.Bullets, s.Pickups = []Player{}, []Bullet{}, []Pickup{}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewBinaryDecoder()
+			base := sample()
+			roundTrip(t, d, &base, nil)
+			next := sample()
+			next.Tick = base.Tick + 1
+			tt.change(&next)
+			f := roundTrip(t, d, &next, &base)
+			if !reflect.DeepEqual(f.World, next) {
+				t.Errorf("decoded\n%+v\nwant\n%+v", f.World, next)
+			}
+		})
+	}
+}
+
+func TestBinaryBaselineIsCopied(t *testing.T) {
+	d := NewBinaryDecoder()
+	base := sample()
+	f := roundTrip(t, d, &base, nil)
+	f.World.Players[0].X = 300
+	f.World.Players[0].Weapons[0] = "changed"
+	f.World.Bullets[0].X = 300
+	next := sample()
+	next.Tick++
+	f = roundTrip(t, d, &next, &base)
+	if !reflect.DeepEqual(f.World, next) {
+		t.Errorf("decoded\n%+v\nwant\n%+v", f.World, next)
+	}
+}
+
+func TestBinaryNewEpoch(t *testing.T) {
+	d := NewBinaryDecoder()
+	base := sample()
+	roundTrip(t, d, &base, nil)
+	next := sample()
+	next.Tick++
+	_, err := d.Decode(BinaryFrame(Header{Epoch: 1}, EncodeBinary(&next, &base)))
+	if errors.Cause(err) != ErrMissingBaseline {
+		t.Errorf("delta against the old epoch got %v, want %v", err, ErrMissingBaseline)
+	}
+}
+
+func TestBinaryDeltaIsSmaller(t *testing.T) {
+	base := sample()
+	next := sample()
+	next.Tick++
+	if full, delta := len(EncodeBinary(&next, nil)), len(EncodeBinary(&next, &base)); delta >= full {
+		t.Errorf("delta of an unchanged snapshot has %d bytes, the full one %d", delta, full)
+	}
+}
+
+func TestBinaryMissingBaseline(t *testing.T) {
+	base := sample()
+	next := sample()
+	next.Tick++
+	_, err := NewBinaryDecoder().Decode(BinaryFrame(Header{}, EncodeBinary(&next, &base)))
+	if errors.Cause(err) != ErrMissingBaseline {
+		t.Errorf("got %v, want %v", err, ErrMissingBaseline)
+	}
+}
+
+func TestBinaryMalformed(t *testing.T) {
+	s := sample()
+	frame := BinaryFrame(Header{}, EncodeBinary(&s, nil))
+
+	// a full frame up to the teams, then count the teams
+	prefix := func(teams uint64) []byte {
+		e := &encoder{}
+		e.uvarint(0)
+		e.uvarint(0)
+		e.uvarint(0)
+		e.byte(frameFull)
+		e.uvarint(1)
+		e.string("")
+		for i := 0; i < 5; i++ {
+			e.varint(0)
+		}
+		e.string("")
+		e.string("")
+		e.uvarint(teams)
+		return e.buf
+	}
+	hugeString := &encoder{}
+	hugeString.uvarint(0)
+	hugeString.uvarint(0)
+	hugeString.uvarint(0)
+	hugeString.byte(frameFull)
+	hugeString.uvarint(1)
+	hugeString.uvarint(1 << 40)
+	hugeString.buf = append(hugeString.buf, "Playing"...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"unknown kind", []byte{0, 0, 0, 9, 1}},
+		{"huge string", hugeString.buf},
+		{"huge count", prefix(1 << 40)},
+		{"count past the end", prefix(3)},
+	}
+	for n := 1; n < len(frame); n++ {
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{"truncated", frame[:n]})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewBinaryDecoder().Decode(tt.data)
+			if errors.Cause(err) != ErrMalformed {
+				t.Errorf("got %v and %+v, want %v", err, f, ErrMalformed)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// TickEncoder turns the snapshot of one tick into frames for all clients.
+// The world is encoded at most once per encoding and delta baseline, each
+// frame only adds the recipient's header to the shared bytes.
+type TickEncoder struct {
+	snapshot *Snapshot
+	history  *History
+	json     []byte
+	binary   map[uint64][]byte
+}
+
+func NewTickEncoder(s *Snapshot, history *History) *TickEncoder {
+	return &TickEncoder{
+		snapshot: s,
+		history:  history,
+		binary:   make(map[uint64][]byte),
+	}
+}
+
+// JSON returns the JSON frame for h.
+func (e *TickEncoder) JSON(h Header) ([]byte, error) {
+	if e.json == nil {
+		world, err := json.Marshal(e.snapshot)
+		if err != nil {
+			return nil, errors.Wrap(err, "error encoding snapshot")
+		}
+		e.json = world
+	}
+	buf := make([]byte, 0, len(e.json)+64)
+	buf = append(buf, `{"v":`...)
+	buf = strconv.AppendInt(buf, Version, 10)
+	buf = append(buf, `,"type":"`...)
+	buf = append(buf, TypeSnapshot...)
+	buf = append(buf, `","you":`...)
+	buf = strconv.AppendUint(buf, uint64(h.You), 10)
+	buf = append(buf, `,"ack":`...)
+	buf = strconv.AppendUint(buf, uint64(h.Ack), 10)
+	buf = append(buf, `,"epoch":`...)
+	buf = strconv.AppendUint(buf, uint64(h.Epoch), 10)
+	buf = append(buf, `,"world":`...)
+	buf = append(buf, e.json...)
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// Binary returns the binary frame for h, as a delta against the snapshot
+// of tick ack if it is still in the history.
+func (e *TickEncoder) Binary(h Header, ack uint64) []byte {
+	base := e.history.Get(ack)
+	var key uint64
+	if base != nil {
+		key = base.Tick + 1
+	}
+	body, ok := e.binary[key]
+	if !ok {
+		body = EncodeBinary(e.snapshot, base)
+		e.binary[key] = body
+	}
+	return BinaryFrame(h, body)
+}
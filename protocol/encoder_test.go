@@ -0,0 +1,66 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/maxxxlounge/websocket/game"
+
+	guuid "github.com/google/uuid"
+)
+
+// benchmarkBroadcast measures the encoding cost of one tick for a game
+// where every player is also a connected client.
+func benchmarkBroadcast(b *testing.B, players int, send func(enc *TickEncoder, s *Snapshot, h Header)) {
+	g := game.New(nil)
+	for i := 0; i < players; i++ {
+		p := g.NewPlayer(guuid.New())
+		p.Fire = true
+		p.Right = i%2 == 0
+		p.Up = i%3 == 0
+	}
+	dt := 1 / float64(g.TickRate())
+	for i := 0; i < g.TickRate(); i++ {
+		g.Step(dt)
+	}
+	var history History
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		g.Step(dt)
+		b.StartTimer()
+		s := NewSnapshot(g, g.TakeEvents())
+		history.Add(&s)
+		enc := NewTickEncoder(&s, &history)
+		for _, p := range g.Players {
+			send(enc, &s, Header{You: p.NetID, Ack: p.InputSeq})
+		}
+	}
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	encodings := []struct {
+		name string
+		send func(enc *TickEncoder, s *Snapshot, h Header)
+	}{
+		{"json-per-client", func(enc *TickEncoder, s *Snapshot, h Header) {
+			json.Marshal(Frame{Envelope: Envelope{Version: Version, Type: TypeSnapshot}, Header: h, World: *s})
+		}},
+		{"json", func(enc *TickEncoder, s *Snapshot, h Header) {
+			enc.JSON(h)
+		}},
+		{"binary-delta", func(enc *TickEncoder, s *Snapshot, h Header) {
+			enc.Binary(h, s.Tick-1)
+		}},
+	}
+	for _, e := range encodings {
+		for _, n := range []int{2, 16, 64} {
+			send := e.send
+			b.Run(fmt.Sprintf("%s/players=%d", e.name, n), func(b *testing.B) {
+				benchmarkBroadcast(b, n, send)
+			})
+		}
+	}
+}
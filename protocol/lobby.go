@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const TypeListRooms MessageType = "list_rooms"
+const TypeCreateRoom MessageType = "create_room"
+const TypeJoinRoom MessageType = "join_room"
+const TypeLeaveRoom MessageType = "leave_room"
+const TypeRooms MessageType = "rooms"
+const TypeJoined MessageType = "joined"
+
+const MaxRoomPlayers = 64
+
+type RoomInfo struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"maxPlayers"`
+	// Bots are the server driven players filling the room, not counted
+	// in Players.
+	Bots int `json:"bots,omitempty"`
+	// TickRate is the number of simulation steps per second, clients that
+	// predict their ship step as often.
+	TickRate int `json:"tickRate"`
+}
+
+type ListRooms struct {
+	Envelope
+}
+
+// CreateRoom opens a new room and joins it. MaxPlayers 0 uses the server
+// default.
+type CreateRoom struct {
+	Envelope
+	Name       string `json:"name"`
+	MaxPlayers int    `json:"maxPlayers,omitempty"`
+}
+
+// JoinRoom moves the client into a room. An empty Room joins any room
+// with a free slot.
+type JoinRoom struct {
+	Envelope
+	Room string `json:"room,omitempty"`
+}
+
+type LeaveRoom struct {
+	Envelope
+}
+
+// Rooms answers ListRooms.
+type Rooms struct {
+	Envelope
+	Rooms []RoomInfo `json:"rooms"`
+}
+
+// Joined confirms JoinRoom and CreateRoom.
+type Joined struct {
+	Envelope
+	Room RoomInfo `json:"room"`
+}
+
+func NewListRooms() ListRooms {
+	return ListRooms{Envelope{Version: Version, Type: TypeListRooms}}
+}
+
+func NewCreateRoom(name string, maxPlayers int) CreateRoom {
+	return CreateRoom{Envelope: Envelope{Version: Version, Type: TypeCreateRoom}, Name: name, MaxPlayers: maxPlayers}
+}
+
+func NewJoinRoom(room string) JoinRoom {
+	return JoinRoom{Envelope: Envelope{Version: Version, Type: TypeJoinRoom}, Room: room}
+}
+
+func NewLeaveRoom() LeaveRoom {
+	return LeaveRoom{Envelope{Version: Version, Type: TypeLeaveRoom}}
+}
+
+func NewRooms(rooms []RoomInfo) Rooms {
+	return Rooms{Envelope: Envelope{Version: Version, Type: TypeRooms}, Rooms: rooms}
+}
+
+func NewJoined(room RoomInfo) Joined {
+	return Joined{Envelope: Envelope{Version: Version, Type: TypeJoined}, Room: room}
+}
+
+func (m *CreateRoom) Validate() error {
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		return errors.Wrap(ErrInvalid, "empty room name")
+	}
+	if len([]rune(m.Name)) > MaxNameLength {
+		return errors.Wrap(ErrInvalid, "room name too long")
+	}
+	if m.MaxPlayers < 0 || m.MaxPlayers > MaxRoomPlayers {
+		return errors.Wrap(ErrInvalid, "max players out of range")
+	}
+	return nil
+}
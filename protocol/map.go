@@ -0,0 +1,50 @@
+package protocol
+
+import (
+	"github.com/maxxxlounge/websocket/game"
+)
+
+const TypeMap MessageType = "map"
+
+// Map is the static part of the arena, sent once when a client joins a
+// room. Shapes are centred on X, Y.
+type Map struct {
+	Envelope
+	Name      string     `json:"name"`
+	Width     float64    `json:"width"`
+	Height    float64    `json:"height"`
+	Walls     []Wall     `json:"walls"`
+	Asteroids []Asteroid `json:"asteroids"`
+}
+
+type Wall struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation float64 `json:"rotation"`
+}
+
+type Asteroid struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Radius float64 `json:"radius"`
+}
+
+func NewMap(m *game.Map) Map {
+	msg := Map{
+		Envelope:  Envelope{Version: Version, Type: TypeMap},
+		Name:      m.Name,
+		Width:     m.Width,
+		Height:    m.Height,
+		Walls:     make([]Wall, 0, len(m.Walls)),
+		Asteroids: make([]Asteroid, 0, len(m.Asteroids)),
+	}
+	for _, w := range m.Walls {
+		msg.Walls = append(msg.Walls, Wall{X: w.X, Y: w.Y, Width: w.Width, Height: w.Height, Rotation: w.Rotation})
+	}
+	for _, a := range m.Asteroids {
+		msg.Asteroids = append(msg.Asteroids, Asteroid{X: a.X, Y: a.Y, Radius: a.Radius})
+	}
+	return msg
+}
@@ -0,0 +1,223 @@
+package protocol
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version is bumped on every incompatible change of the wire format.
+const Version = 3
+
+type MessageType string
+
+const TypeInput MessageType = "input"
+const TypeSetup MessageType = "setup"
+const TypePause MessageType = "pause"
+const TypeResume MessageType = "resume"
+const TypeError MessageType = "error"
+const TypeAck MessageType = "ack"
+
+// Snapshot encodings a client can ask for with the encoding query
+// parameter of /connect. Other messages are always JSON.
+const EncodingJSON = "json"
+const EncodingBinary = "binary"
+
+const MaxNameLength = 24
+
+var ErrMalformed = errors.New("malformed message")
+var ErrVersion = errors.New("unsupported protocol version")
+var ErrUnknownType = errors.New("unknown message type")
+var ErrInvalid = errors.New("invalid message")
+
+// ErrRoom is the cause of errors joining or creating rooms.
+var ErrRoom = errors.New("room not available")
+
+// Envelope is embedded in every message, so all of them encode as a flat
+// object like {"v":1,"type":"input","seq":12,"left":true}.
+type Envelope struct {
+	Version int         `json:"v"`
+	Type    MessageType `json:"type"`
+}
+
+// Input is the complete input state of a client. Seq grows with every
+// message so the server can drop stale ones.
+type Input struct {
+	Envelope
+	Seq   uint32  `json:"seq"`
+	Left  bool    `json:"left,omitempty"`
+	Right bool    `json:"right,omitempty"`
+	Up    bool    `json:"up,omitempty"`
+	Down  bool    `json:"down,omitempty"`
+	Fire  bool    `json:"fire,omitempty"`
+	AxisX float64 `json:"axisX,omitempty"`
+	AxisY float64 `json:"axisY,omitempty"`
+	// Weapon is the name of the weapon to use, empty keeps the current one.
+	Weapon string `json:"weapon,omitempty"`
+}
+
+type Setup struct {
+	Envelope
+	Name string `json:"name"`
+}
+
+type Pause struct {
+	Envelope
+}
+
+type Resume struct {
+	Envelope
+}
+
+// Ack tells the server the last snapshot the client received, binary
+// snapshots are then sent as a delta against it. Epoch is the one of the
+// snapshot's frame.
+type Ack struct {
+	Envelope
+	Epoch uint32 `json:"epoch"`
+	Tick  uint64 `json:"tick"`
+}
+
+// Error is sent by the server when it can't handle a client message.
+type Error struct {
+	Envelope
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func NewInput(seq uint32) Input {
+	return Input{Envelope: Envelope{Version: Version, Type: TypeInput}, Seq: seq}
+}
+
+func NewSetup(name string) Setup {
+	return Setup{Envelope: Envelope{Version: Version, Type: TypeSetup}, Name: name}
+}
+
+func NewAck(epoch uint32, tick uint64) Ack {
+	return Ack{Envelope: Envelope{Version: Version, Type: TypeAck}, Epoch: epoch, Tick: tick}
+}
+
+func NewPause() Pause {
+	return Pause{Envelope{Version: Version, Type: TypePause}}
+}
+
+func NewResume() Resume {
+	return Resume{Envelope{Version: Version, Type: TypeResume}}
+}
+
+// NewError builds the reply for an error returned by Decode.
+func NewError(err error) Error {
+	code := "internal"
+	switch errors.Cause(err) {
+	case ErrRoom:
+		code = "room"
+	case ErrMalformed:
+		code = "malformed"
+	case ErrVersion:
+		code = "version"
+	case ErrUnknownType:
+		code = "unknown_type"
+	case ErrInvalid:
+		code = "invalid"
+	}
+	return Error{
+		Envelope: Envelope{Version: Version, Type: TypeError},
+		Code:     code,
+		Message:  err.Error(),
+	}
+}
+
+func (m *Input) Validate() error {
+	for _, a := range []float64{m.AxisX, m.AxisY} {
+		if math.IsNaN(a) || a < -1 || a > 1 {
+			return errors.Wrap(ErrInvalid, "axis out of range")
+		}
+	}
+	if len(m.Weapon) > MaxNameLength {
+		return errors.Wrap(ErrInvalid, "weapon name too long")
+	}
+	return nil
+}
+
+func (m *Setup) Validate() error {
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		return errors.Wrap(ErrInvalid, "empty name")
+	}
+	if len([]rune(m.Name)) > MaxNameLength {
+		return errors.Wrap(ErrInvalid, "name too long")
+	}
+	return nil
+}
+
+func Encode(msg interface{}) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding message")
+	}
+	return data, nil
+}
+
+// clientMessages and serverMessages create an empty message for each type
+// a side may receive.
+var clientMessages = map[MessageType]func() interface{}{
+	TypeInput:  func() interface{} { return &Input{} },
+	TypeSetup:  func() interface{} { return &Setup{} },
+	TypePause:  func() interface{} { return &Pause{} },
+	TypeResume: func() interface{} { return &Resume{} },
+	TypeAck:    func() interface{} { return &Ack{} },
+
+	TypeListRooms:  func() interface{} { return &ListRooms{} },
+	TypeCreateRoom: func() interface{} { return &CreateRoom{} },
+	TypeJoinRoom:   func() interface{} { return &JoinRoom{} },
+	TypeLeaveRoom:  func() interface{} { return &LeaveRoom{} },
+}
+
+var serverMessages = map[MessageType]func() interface{}{
+	TypeSnapshot: func() interface{} { return &Frame{} },
+	TypeError:    func() interface{} { return &Error{} },
+	TypeRooms:    func() interface{} { return &Rooms{} },
+	TypeJoined:   func() interface{} { return &Joined{} },
+	TypeMap:      func() interface{} { return &Map{} },
+}
+
+// Decode parses and validates a message sent by a client. It returns a
+// pointer to one of the message types of this package.
+func Decode(data []byte) (interface{}, error) {
+	return decode(data, clientMessages)
+}
+
+// DecodeServer parses a message sent by the server.
+func DecodeServer(data []byte) (interface{}, error) {
+	return decode(data, serverMessages)
+}
+
+func decode(data []byte, types map[MessageType]func() interface{}) (interface{}, error) {
+	var e Envelope
+	err := json.Unmarshal(data, &e)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, err.Error())
+	}
+	if e.Version != Version {
+		return nil, errors.Wrapf(ErrVersion, "got %d, want %d", e.Version, Version)
+	}
+
+	newMsg, ok := types[e.Type]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownType, "%q", e.Type)
+	}
+	msg := newMsg()
+	err = json.Unmarshal(data, msg)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, err.Error())
+	}
+	if v, ok := msg.(interface{ Validate() error }); ok {
+		err = v.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,42 @@
+package protocol
+
+import "testing"
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		code string
+	}{
+		{"not json", `{"v":3,"type":`, "malformed"},
+		{"wrong field type", `{"v":3,"type":"input","seq":"one"}`, "malformed"},
+		{"old version", `{"v":2,"type":"input","seq":1}`, "version"},
+		{"no version", `{"type":"input","seq":1}`, "version"},
+		{"unknown type", `{"v":3,"type":"cheat"}`, "unknown_type"},
+		{"server message", `{"v":3,"type":"snapshot"}`, "unknown_type"},
+		{"axis out of range", `{"v":3,"type":"input","seq":1,"axisX":2}`, "invalid"},
+		{"empty name", `{"v":3,"type":"setup","name":""}`, "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Decode([]byte(tt.data))
+			if err == nil {
+				t.Fatalf("decoded %+v, want the %s error", msg, tt.code)
+			}
+			if code := NewError(err).Code; code != tt.code {
+				t.Errorf("got %s for %v, want %s", code, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestDecodeInput(t *testing.T) {
+	msg, err := Decode([]byte(`{"v":3,"type":"input","seq":7,"left":true,"weapon":"spread"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, ok := msg.(*Input)
+	if !ok || in.Seq != 7 || !in.Left || in.Weapon != "spread" {
+		t.Errorf("decoded %+v, want input 7 turning left with the spread", msg)
+	}
+}
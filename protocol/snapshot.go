@@ -0,0 +1,335 @@
+package protocol
+
+import (
+	"github.com/maxxxlounge/websocket/game"
+
+	"github.com/faiface/pixel"
+)
+
+const TypeSnapshot MessageType = "snapshot"
+
+// Game statuses as sent in Snapshot.Status.
+const StatusWaitForPlayer = "WaitForPlayer"
+const StatusCountdown = "Countdown"
+const StatusPlaying = "Playing"
+const StatusScoreboard = "Scoreboard"
+
+// StatusEliminated is the Player.Status of players out until the next
+// match.
+const StatusEliminated = "Eliminated"
+
+// Frame is the snapshot message. The world is the same for every client
+// of a game and encoded once per tick, only the header differs.
+type Frame struct {
+	Envelope
+	Header
+	World Snapshot `json:"world"`
+}
+
+// Header is the part of a frame specific to its recipient: the id of its
+// player and the last input sequence the server applied. Epoch changes
+// whenever the client joins a room, older baselines and acks are void.
+type Header struct {
+	You   uint32 `json:"you"`
+	Ack   uint32 `json:"ack"`
+	Epoch uint32 `json:"epoch"`
+}
+
+// Snapshot is the world state sent to clients after every tick. It only
+// depends on the types below, never on the game package, so the
+// simulation can change without breaking clients.
+type Snapshot struct {
+	Tick   uint64 `json:"tick"`
+	Status string `json:"status"`
+	// StatusTime is the time left in the current status in seconds: the
+	// countdown, the match time or the time the scoreboard is shown.
+	StatusTime float64  `json:"statusTime"`
+	Mode       string   `json:"mode"`
+	Bounds     Bounds   `json:"bounds"`
+	Teams      []Team   `json:"teams,omitempty"`
+	Flags      []Flag   `json:"flags,omitempty"`
+	Zone       *Zone    `json:"zone,omitempty"`
+	Players    []Player `json:"players"`
+	Bullets    []Bullet `json:"bullets"`
+	Pickups    []Pickup `json:"pickups"`
+	Events     []Event  `json:"events,omitempty"`
+}
+
+// Bounds is the arena from X, Y to X+Width, Y+Height.
+type Bounds struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Edge   string  `json:"edge"`
+}
+
+// Team is a side of a team match, Color is the colour its ships are
+// tinted with as "#rrggbb".
+type Team struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Score int    `json:"score"`
+}
+
+// Flag is a flag in capture the flag. Carrier is the id of the player
+// carrying it, 0 if it lies around or stands at its base.
+type Flag struct {
+	ID      uint32  `json:"id"`
+	Team    int     `json:"team"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	BaseX   float64 `json:"baseX"`
+	BaseY   float64 `json:"baseY"`
+	Carrier uint32  `json:"carrier,omitempty"`
+}
+
+// Zone is the hill of king of the hill.
+type Zone struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Radius float64 `json:"radius"`
+}
+
+type Player struct {
+	ID          uint32  `json:"id"`
+	Name        string  `json:"name"`
+	X           float64 `json:"x"`
+	Y           float64 `json:"y"`
+	VX          float64 `json:"vx"`
+	VY          float64 `json:"vy"`
+	Rotation    float64 `json:"rotation"`
+	Life        float64 `json:"life"`
+	Score       int     `json:"score"`
+	Status      string  `json:"status"`
+	Hits        int     `json:"hits"`
+	DamageDealt float64 `json:"damageDealt"`
+	Kills       int     `json:"kills"`
+	Deaths      int     `json:"deaths"`
+	Assists     int     `json:"assists"`
+	// RespawnTime is the time left until a dead player respawns,
+	// Invulnerable the time left of the spawn protection.
+	RespawnTime  float64 `json:"respawnTime,omitempty"`
+	Invulnerable float64 `json:"invulnerable,omitempty"`
+	// the seconds left of the timed pickups
+	RapidFire   float64 `json:"rapidFire,omitempty"`
+	DamageBoost float64 `json:"damageBoost,omitempty"`
+	Shield      float64 `json:"shield,omitempty"`
+	SpeedBoost  float64 `json:"speedBoost,omitempty"`
+	// Weapon is the selected weapon and Ammo its shots left, 0 is
+	// unlimited. Weapons are all weapons the player carries.
+	Weapon  string   `json:"weapon"`
+	Ammo    int      `json:"ammo,omitempty"`
+	Weapons []string `json:"weapons"`
+	// Bot is set for players driven by the server.
+	Bot bool `json:"bot,omitempty"`
+	// Team is the id of the player's team, 0 outside team matches.
+	Team int `json:"team,omitempty"`
+}
+
+type Bullet struct {
+	ID       uint32  `json:"id"`
+	Owner    uint32  `json:"owner"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	VX       float64 `json:"vx"`
+	VY       float64 `json:"vy"`
+	Rotation float64 `json:"rotation"`
+	Weapon   string  `json:"weapon"`
+}
+
+type Pickup struct {
+	ID     uint32  `json:"id"`
+	Kind   string  `json:"kind"`
+	Weapon string  `json:"weapon,omitempty"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+}
+
+type Event struct {
+	Type   string  `json:"type"`
+	Player uint32  `json:"player,omitempty"`
+	Other  uint32  `json:"other,omitempty"`
+	Value  float64 `json:"value,omitempty"`
+	Status string  `json:"status,omitempty"`
+	Pickup string  `json:"pickup,omitempty"`
+	Team   int     `json:"team,omitempty"`
+}
+
+// NewSnapshot converts the current state of g. events are the ones taken
+// from g since the previous snapshot.
+func NewSnapshot(g *game.Game, events []game.Event) Snapshot {
+	s := newSnapshot(g, events, len(g.Players), len(g.Bullets))
+	for _, k := range g.Pickups {
+		s.Pickups = append(s.Pickups, newPickup(k))
+	}
+	for _, p := range g.Players {
+		s.Players = append(s.Players, newPlayer(p))
+	}
+	for _, b := range g.Bullets {
+		if b.Exhausted {
+			continue
+		}
+		s.Bullets = append(s.Bullets, newBullet(g, b))
+	}
+	return s
+}
+
+// NewSnapshotNear is the snapshot as seen by viewer: only the ships and
+// bullets within radius of it. Between matches everybody is sent so the
+// scoreboard is complete.
+func NewSnapshotNear(g *game.Game, events []game.Event, viewer *game.Player, radius float64) Snapshot {
+	if g.Status != game.Playing {
+		return NewSnapshot(g, events)
+	}
+	pos := pixel.V(viewer.X, viewer.Y)
+	players := g.PlayersNear(pos, radius)
+	bullets := g.BulletsNear(pos, radius)
+	s := newSnapshot(g, events, len(players)+1, len(bullets))
+	// pickups are few and worth heading for from afar, send them all
+	for _, k := range g.Pickups {
+		s.Pickups = append(s.Pickups, newPickup(k))
+	}
+	// dead players are not indexed but still need their own state
+	if viewer.Life <= 0 {
+		s.Players = append(s.Players, newPlayer(viewer))
+	}
+	for _, p := range players {
+		s.Players = append(s.Players, newPlayer(p))
+	}
+	for _, b := range bullets {
+		if b.Exhausted {
+			continue
+		}
+		s.Bullets = append(s.Bullets, newBullet(g, b))
+	}
+	return s
+}
+
+func newSnapshot(g *game.Game, events []game.Event, players, bullets int) Snapshot {
+	s := Snapshot{
+		Tick:       g.Tick,
+		Status:     string(g.Status),
+		StatusTime: g.StatusTime,
+		Mode:       g.Mode().Name(),
+		Bounds: Bounds{
+			X:      g.Bounds.X,
+			Y:      g.Bounds.Y,
+			Width:  g.Bounds.Width,
+			Height: g.Bounds.Height,
+			Edge:   string(g.Bounds.Edge),
+		},
+		Players: make([]Player, 0, players),
+		Bullets: make([]Bullet, 0, bullets),
+		Pickups: make([]Pickup, 0, len(g.Pickups)),
+	}
+	for _, t := range g.Teams {
+		s.Teams = append(s.Teams, Team{ID: t.ID, Name: t.Name, Color: t.Color, Score: t.Score})
+	}
+	// like pickups flags are the goal, everybody always sees them
+	for _, f := range g.Flags {
+		dto := Flag{ID: f.NetID, Team: f.Team, X: f.X, Y: f.Y, BaseX: f.Base.X, BaseY: f.Base.Y}
+		if f.Carrier != nil {
+			dto.Carrier = f.Carrier.NetID
+		}
+		s.Flags = append(s.Flags, dto)
+	}
+	if z := g.Zone; z != nil {
+		s.Zone = &Zone{X: z.X, Y: z.Y, Radius: z.Radius}
+	}
+	for _, e := range events {
+		ev := Event{
+			Type:   string(e.Type),
+			Value:  e.Value,
+			Status: string(e.Status),
+			Pickup: string(e.Pickup),
+			Team:   e.Team,
+		}
+		if e.Player != nil {
+			ev.Player = e.Player.NetID
+		}
+		if e.Other != nil {
+			ev.Other = e.Other.NetID
+		}
+		s.Events = append(s.Events, ev)
+	}
+	return s
+}
+
+func newPlayer(p *game.Player) Player {
+	dto := Player{
+		ID:           p.NetID,
+		Name:         p.Name,
+		X:            p.X,
+		Y:            p.Y,
+		VX:           p.Velocity.X,
+		VY:           p.Velocity.Y,
+		Rotation:     float64(p.Rotation),
+		Life:         p.Life,
+		Score:        p.Score,
+		Status:       string(p.Status),
+		Hits:         p.Hits,
+		DamageDealt:  p.DamageDealt,
+		Kills:        p.Kills,
+		Deaths:       p.Deaths,
+		Assists:      p.Assists,
+		RespawnTime:  p.RespawnTime,
+		Invulnerable: p.Invulnerable,
+		RapidFire:    p.Effects.RapidFire,
+		DamageBoost:  p.Effects.Damage,
+		Shield:       p.Effects.Shield,
+		SpeedBoost:   p.Effects.Speed,
+		Weapons:      make([]string, 0, len(p.Inventory)),
+		Bot:          p.Bot,
+		Team:         p.Team,
+	}
+	if w := p.Weapon(); w != nil {
+		dto.Weapon = w.Name
+		dto.Ammo = p.Inventory[p.Slot].Ammo
+	}
+	for _, s := range p.Inventory {
+		dto.Weapons = append(dto.Weapons, s.Weapon.Name)
+	}
+	return dto
+}
+
+func newBullet(g *game.Game, b *game.Bullet) Bullet {
+	var owner uint32
+	if p := g.GetPlayer(b.Owner); p != nil {
+		owner = p.NetID
+	}
+	return Bullet{
+		ID:       b.NetID,
+		Owner:    owner,
+		X:        b.X,
+		Y:        b.Y,
+		VX:       b.Velocity.X,
+		VY:       b.Velocity.Y,
+		Rotation: float64(b.Rotation),
+		Weapon:   b.Weapon,
+	}
+}
+
+// Player returns the player with the given id or nil.
+func (s *Snapshot) Player(id uint32) *Player {
+	for i := range s.Players {
+		if s.Players[i].ID == id {
+			return &s.Players[i]
+		}
+	}
+	return nil
+}
+
+func newPickup(k *game.Pickup) Pickup {
+	dto := Pickup{
+		ID:   k.NetID,
+		Kind: string(k.Kind),
+		X:    k.X,
+		Y:    k.Y,
+	}
+	if k.Weapon != nil {
+		dto.Weapon = k.Weapon.Name
+	}
+	return dto
+}
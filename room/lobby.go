@@ -0,0 +1,116 @@
+package room
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/maxxxlounge/websocket/protocol"
+
+	guuid "github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Lobby keeps track of the open rooms. Rooms remove themselves once they
+// were empty for longer than Config.IdleTimeout.
+type Lobby struct {
+	cfg Config
+
+	mu      sync.Mutex
+	rooms   map[string]*Room
+	order   []*Room
+	counter int
+
+	// quickJoin serializes QuickJoin so concurrent clients fill a room
+	// instead of opening one each.
+	quickJoin sync.Mutex
+}
+
+func NewLobby(cfg Config) *Lobby {
+	return &Lobby{
+		cfg:   cfg,
+		rooms: make(map[string]*Room),
+	}
+}
+
+// Create opens a new room. maxPlayers 0 uses the lobby default.
+func (l *Lobby) Create(name string, maxPlayers int) *Room {
+	cfg := l.cfg
+	if maxPlayers > 0 {
+		cfg.MaxPlayers = maxPlayers
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counter++
+	if name == "" {
+		name = fmt.Sprintf("Room %d", l.counter)
+	}
+	r := newRoom(guuid.New().String()[:8], name, cfg, l.remove)
+	l.rooms[r.ID] = r
+	l.order = append(l.order, r)
+	return r
+}
+
+func (l *Lobby) remove(r *Room) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.rooms, r.ID)
+	for i, o := range l.order {
+		if o == r {
+			l.order = append(l.order[:i], l.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (l *Lobby) Get(id string) (*Room, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.rooms[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "%q", id)
+	}
+	return r, nil
+}
+
+// rooms returns the open rooms, oldest first.
+func (l *Lobby) openRooms() []*Room {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rooms := make([]*Room, len(l.order))
+	copy(rooms, l.order)
+	return rooms
+}
+
+func (l *Lobby) List() []protocol.RoomInfo {
+	var infos []protocol.RoomInfo
+	for _, r := range l.openRooms() {
+		infos = append(infos, r.Info())
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// QuickJoin joins the oldest room with a free slot and opens a new room
+// when all of them are full.
+func (l *Lobby) QuickJoin(id guuid.UUID, c Conn, encoding string) (*Room, error) {
+	l.quickJoin.Lock()
+	defer l.quickJoin.Unlock()
+	for _, r := range l.openRooms() {
+		err := r.Join(id, c, encoding)
+		if err == nil {
+			return r, nil
+		}
+		if err != ErrFull && err != ErrClosed {
+			return nil, err
+		}
+	}
+	r := l.Create("", 0)
+	err := r.Join(id, c, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,119 @@
+package room
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maxxxlounge/websocket/game"
+	"github.com/maxxxlounge/websocket/protocol"
+
+	guuid "github.com/google/uuid"
+)
+
+// fakeConn collects what a room sends to a client.
+type fakeConn struct {
+	mu      sync.Mutex
+	frames  [][]byte
+	control int
+}
+
+func (c *fakeConn) SendMessage(typ int, msg []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frames = append(c.frames, msg)
+	return true
+}
+
+func (c *fakeConn) SendControl(typ int, msg []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.control++
+	return true
+}
+
+func (c *fakeConn) take() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frames := c.frames
+	c.frames = nil
+	return frames
+}
+
+// TestLobbyConcurrency lets dozens of clients join, play, acknowledge
+// and leave at the same time. Run it with -race.
+func TestLobbyConcurrency(t *testing.T) {
+	match := game.DefaultMatchConfig()
+	match.MinPlayers = 1
+	match.Teams = 2
+	lobby := NewLobby(Config{
+		TickRate:    60,
+		MaxPlayers:  8,
+		Match:       match,
+		IdleTimeout: 50 * time.Millisecond,
+		Bots:        4,
+	})
+	const clients = 40
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, conn := guuid.New(), &fakeConn{}
+			encoding := protocol.EncodingJSON
+			if i%2 == 0 {
+				encoding = protocol.EncodingBinary
+			}
+			var r *Room
+			var err error
+			if i%10 == 0 {
+				r = lobby.Create("", 0)
+				err = r.Join(id, conn, encoding)
+			} else {
+				r, err = lobby.QuickJoin(id, conn, encoding)
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			dec := protocol.NewBinaryDecoder()
+			for seq := uint32(1); seq <= 50; seq++ {
+				in := protocol.NewInput(seq)
+				in.Fire = true
+				in.Left = seq%2 == 0
+				in.Up = seq%3 == 0
+				r.Input(id, &in)
+				if seq == 25 {
+					lobby.List()
+				}
+				for _, data := range conn.take() {
+					if encoding != protocol.EncodingBinary {
+						continue
+					}
+					f, err := dec.Decode(data)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					r.Input(id, &protocol.Ack{Epoch: f.Epoch, Tick: f.World.Tick})
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+			r.Leave(id)
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+			if conn.control == 0 {
+				t.Errorf("client %d never got the map", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// the rooms close once they were empty for the idle timeout
+	deadline := time.Now().Add(2 * time.Second)
+	for len(lobby.List()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rooms := lobby.List(); len(rooms) > 0 {
+		t.Errorf("%d rooms still open", len(rooms))
+	}
+}
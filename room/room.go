@@ -0,0 +1,358 @@
+package room
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/maxxxlounge/websocket/bot"
+	"github.com/maxxxlounge/websocket/game"
+	"github.com/maxxxlounge/websocket/protocol"
+
+	guuid "github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+var ErrFull = errors.Wrap(protocol.ErrRoom, "room is full")
+var ErrClosed = errors.Wrap(protocol.ErrRoom, "room is closed")
+var ErrNotFound = errors.Wrap(protocol.ErrRoom, "room not found")
+
+// epochs hands out the epoch of every join, across all rooms.
+var epochs uint32
+
+// Conn is the part of a client connection a room needs: non-blocking
+// sends of websocket messages. SendMessage may drop stale snapshots for
+// newer ones, SendControl delivers everything.
+type Conn interface {
+	SendMessage(typ int, msg []byte) bool
+	SendControl(typ int, msg []byte) bool
+}
+
+type Config struct {
+	TickRate   int
+	MaxPlayers int
+	Match      game.MatchConfig
+	// Map is the arena of the room's games, nil for the default one.
+	Map *game.Map
+	// Weapons are the weapons of the room's games, nil for the defaults.
+	Weapons *game.WeaponRegistry
+	// IdleTimeout is how long a room stays open without players.
+	IdleTimeout time.Duration
+	// ViewRadius limits the snapshots of a player to what is that close to
+	// its ship, 0 sends the whole arena.
+	ViewRadius float64
+	// Bots fill the game up to this many players while a client is in
+	// the room, 0 for no bots. Clients take the place of a bot when they
+	// join.
+	Bots          int
+	BotDifficulty bot.Difficulty
+	// Log prints every JSON frame sent.
+	Log bool
+}
+
+// Room hosts one game. The game and the members are owned by the room's
+// goroutine, everybody else talks to it through Join, Leave and Input.
+type Room struct {
+	ID   string
+	Name string
+	cfg  Config
+
+	game    *game.Game
+	members map[guuid.UUID]*member
+	history protocol.History
+	bots    map[guuid.UUID]*bot.Bot
+	// botNames counts the bots added, for their names
+	botNames int
+
+	joins   chan join
+	leaves  chan guuid.UUID
+	inputs  chan input
+	infos   chan chan protocol.RoomInfo
+	done    chan struct{}
+	onClose func(*Room)
+}
+
+// member is a client in the room. Ack is the last snapshot tick it
+// acknowledged, first to sent the ticks it was sent since it joined or
+// resumed. With a view radius every member sees a different world, so it
+// keeps its own delta history.
+type member struct {
+	conn     Conn
+	encoding string
+	epoch    uint32
+	ack      uint64
+	first    uint64
+	sent     uint64
+	history  protocol.History
+}
+
+type join struct {
+	id       guuid.UUID
+	conn     Conn
+	encoding string
+	result   chan error
+}
+
+type input struct {
+	id  guuid.UUID
+	msg interface{}
+}
+
+func newRoom(id, name string, cfg Config, onClose func(*Room)) *Room {
+	if cfg.BotDifficulty.Name == "" {
+		cfg.BotDifficulty = bot.Normal
+	}
+	g := game.New(cfg.Map)
+	g.SetTickRate(cfg.TickRate)
+	g.SetMatchConfig(cfg.Match)
+	g.SetWeapons(cfg.Weapons)
+	r := &Room{
+		ID:      id,
+		Name:    name,
+		cfg:     cfg,
+		game:    g,
+		members: make(map[guuid.UUID]*member),
+		bots:    make(map[guuid.UUID]*bot.Bot),
+		joins:   make(chan join),
+		leaves:  make(chan guuid.UUID),
+		inputs:  make(chan input, 256),
+		infos:   make(chan chan protocol.RoomInfo),
+		done:    make(chan struct{}),
+		onClose: onClose,
+	}
+	go r.run()
+	return r
+}
+
+// Join adds a player for the client id to the game. It blocks until the
+// room accepted or refused the client.
+func (r *Room) Join(id guuid.UUID, c Conn, encoding string) error {
+	result := make(chan error, 1)
+	select {
+	case r.joins <- join{id: id, conn: c, encoding: encoding, result: result}:
+		return <-result
+	case <-r.done:
+		return ErrClosed
+	}
+}
+
+func (r *Room) Leave(id guuid.UUID) {
+	select {
+	case r.leaves <- id:
+	case <-r.done:
+	}
+}
+
+// Input queues a decoded client message for the player of id.
+func (r *Room) Input(id guuid.UUID, msg interface{}) {
+	select {
+	case r.inputs <- input{id: id, msg: msg}:
+	case <-r.done:
+	}
+}
+
+// Info returns the current state of the room for room listings.
+func (r *Room) Info() protocol.RoomInfo {
+	reply := make(chan protocol.RoomInfo, 1)
+	select {
+	case r.infos <- reply:
+		return <-reply
+	case <-r.done:
+		return protocol.RoomInfo{ID: r.ID, Name: r.Name, MaxPlayers: r.cfg.MaxPlayers, TickRate: r.game.TickRate()}
+	}
+}
+
+func (r *Room) info() protocol.RoomInfo {
+	return protocol.RoomInfo{
+		ID:         r.ID,
+		Name:       r.Name,
+		Players:    len(r.members),
+		MaxPlayers: r.cfg.MaxPlayers,
+		Bots:       len(r.bots),
+		TickRate:   r.game.TickRate(),
+	}
+}
+
+func (r *Room) run() {
+	fmt.Printf("room %s (%s) opened\n", r.ID, r.Name)
+	ticker := time.NewTicker(time.Second / time.Duration(r.game.TickRate()))
+	defer ticker.Stop()
+	last := time.Now()
+	emptySince := last
+	for {
+		select {
+		case j := <-r.joins:
+			if len(r.members) >= r.cfg.MaxPlayers {
+				j.result <- ErrFull
+				break
+			}
+			r.members[j.id] = &member{conn: j.conn, encoding: j.encoding, epoch: atomic.AddUint32(&epochs, 1)}
+			r.fillBots()
+			r.game.NewPlayer(j.id)
+			j.result <- nil
+			r.sendMap(j.conn)
+		case id := <-r.leaves:
+			if _, ok := r.members[id]; !ok {
+				break
+			}
+			delete(r.members, id)
+			r.game.DeletePlayer(id)
+			r.fillBots()
+			if len(r.members) == 0 {
+				emptySince = time.Now()
+			}
+		case in := <-r.inputs:
+			r.handleInput(in)
+		case reply := <-r.infos:
+			reply <- r.info()
+		case now := <-ticker.C:
+			if len(r.members) == 0 && now.Sub(emptySince) > r.cfg.IdleTimeout {
+				r.close()
+				return
+			}
+			elapsed := now.Sub(last).Seconds()
+			last = now
+			r.driveBots(elapsed)
+			if r.game.Update(elapsed) == 0 {
+				continue
+			}
+			r.broadcast()
+		}
+	}
+}
+
+// sendMap sends the static arena, the snapshots only carry what moves.
+func (r *Room) sendMap(c Conn) {
+	msg, err := protocol.Encode(protocol.NewMap(r.game.Map))
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	c.SendControl(websocket.TextMessage, msg)
+}
+
+// fillBots adds or removes bots so the game has cfg.Bots players. Empty
+// rooms have no bots, they would only play among themselves.
+func (r *Room) fillBots() {
+	want := 0
+	if len(r.members) > 0 {
+		want = r.cfg.Bots - len(r.members)
+		if free := r.cfg.MaxPlayers - len(r.members); want > free {
+			want = free
+		}
+	}
+	for id := range r.bots {
+		if len(r.bots) <= want {
+			break
+		}
+		delete(r.bots, id)
+		r.game.DeletePlayer(id)
+	}
+	for len(r.bots) < want {
+		r.botNames++
+		b := bot.New(guuid.New(), r.cfg.BotDifficulty)
+		p := r.game.NewPlayer(b.ID)
+		p.Name = fmt.Sprintf("Bot %d", r.botNames)
+		p.Bot = true
+		r.bots[b.ID] = b
+	}
+}
+
+// driveBots sets the controls of every bot for the next elapsed seconds.
+func (r *Room) driveBots(elapsed float64) {
+	for id, b := range r.bots {
+		if p := r.game.GetPlayer(id); p != nil {
+			p.SetInput(b.Update(r.game, elapsed))
+		}
+	}
+}
+
+func (r *Room) close() {
+	close(r.done)
+	if r.onClose != nil {
+		r.onClose(r)
+	}
+	fmt.Printf("room %s (%s) closed\n", r.ID, r.Name)
+}
+
+func (r *Room) handleInput(in input) {
+	if ack, ok := in.msg.(*protocol.Ack); ok {
+		// acks of another room or of ticks never sent would pick a wrong baseline
+		m := r.members[in.id]
+		if m != nil && ack.Epoch == m.epoch && ack.Tick > m.ack && ack.Tick >= m.first && ack.Tick <= m.sent {
+			m.ack = ack.Tick
+		}
+		return
+	}
+	p := r.game.GetPlayer(in.id)
+	if p == nil {
+		return
+	}
+	switch m := in.msg.(type) {
+	case *protocol.Input:
+		p.SetInput(game.Input{
+			Seq:    m.Seq,
+			Left:   m.Left,
+			Right:  m.Right,
+			Up:     m.Up,
+			Down:   m.Down,
+			Fire:   m.Fire,
+			AxisX:  m.AxisX,
+			AxisY:  m.AxisY,
+			Weapon: m.Weapon,
+		})
+	case *protocol.Setup:
+		p.Name = m.Name
+	case *protocol.Pause:
+		p.Paused = true
+	case *protocol.Resume:
+		p.Paused = false
+	}
+}
+
+// broadcast sends the state of the last tick to every member. The world
+// is encoded once and shared, see protocol.TickEncoder, unless the room
+// has a view radius.
+func (r *Room) broadcast() {
+	events := r.game.TakeEvents()
+	var shared *protocol.TickEncoder
+	if r.cfg.ViewRadius <= 0 {
+		snap := protocol.NewSnapshot(r.game, events)
+		r.history.Add(&snap)
+		shared = protocol.NewTickEncoder(&snap, &r.history)
+	}
+	for id, m := range r.members {
+		p := r.game.GetPlayer(id)
+		if p == nil {
+			continue
+		}
+		if p.Paused {
+			continue
+		}
+		enc := shared
+		if enc == nil {
+			snap := protocol.NewSnapshotNear(r.game, events, p, r.cfg.ViewRadius)
+			m.history.Add(&snap)
+			enc = protocol.NewTickEncoder(&snap, &m.history)
+		}
+		if m.sent+1 != r.game.Tick {
+			m.first = r.game.Tick
+		}
+		m.sent = r.game.Tick
+		h := protocol.Header{You: p.NetID, Ack: p.InputSeq, Epoch: m.epoch}
+		if m.encoding == protocol.EncodingBinary {
+			m.conn.SendMessage(websocket.BinaryMessage, enc.Binary(h, m.ack))
+			continue
+		}
+		msg, err := enc.JSON(h)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		m.conn.SendMessage(websocket.TextMessage, msg)
+		if r.cfg.Log {
+			fmt.Printf("%v\n", string(msg))
+		}
+	}
+}
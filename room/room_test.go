@@ -0,0 +1,77 @@
+package room
+
+import (
+	"testing"
+
+	"github.com/maxxxlounge/websocket/game"
+	"github.com/maxxxlounge/websocket/protocol"
+
+	guuid "github.com/google/uuid"
+)
+
+// pausedRoom returns a room without its goroutine, so the test can drive
+// the game itself, and the player of a client in it.
+func pausedRoom() (*Room, *game.Player) {
+	g := game.New(nil)
+	g.SetMatchConfig(game.DefaultMatchConfig())
+	r := &Room{game: g, members: make(map[guuid.UUID]*member)}
+	id := guuid.New()
+	r.members[id] = &member{}
+	return r, g.NewPlayer(id)
+}
+
+func run(g *game.Game, seconds float64) {
+	dt := 1 / float64(g.TickRate())
+	for t := 0.0; t < seconds; t += dt {
+		g.Step(dt)
+	}
+}
+
+func TestPauseWhileDead(t *testing.T) {
+	r, p := pausedRoom()
+	p.Life, p.Status, p.RespawnTime = 0, game.Died, 1
+	r.handleInput(input{id: p.UUID, msg: &protocol.Pause{}})
+	run(r.game, 2)
+	r.handleInput(input{id: p.UUID, msg: &protocol.Resume{}})
+	run(r.game, 3)
+	if p.Life != game.MaxLife || p.Status != game.Ready {
+		t.Errorf("paused dead player has life %v and status %s, want a respawn", p.Life, p.Status)
+	}
+}
+
+func TestPauseWhileProtected(t *testing.T) {
+	r, p := pausedRoom()
+	if p.Status != game.Respawn || p.Invulnerable <= 0 {
+		t.Fatalf("fresh player has status %s and protection %v", p.Status, p.Invulnerable)
+	}
+	r.handleInput(input{id: p.UUID, msg: &protocol.Pause{}})
+	r.handleInput(input{id: p.UUID, msg: &protocol.Resume{}})
+	run(r.game, 3)
+	if p.Invulnerable != 0 || p.Status != game.Ready {
+		t.Errorf("resumed player has protection %v and status %s, want it gone", p.Invulnerable, p.Status)
+	}
+}
+
+func TestAck(t *testing.T) {
+	tests := []struct {
+		name string
+		ack  protocol.Ack
+		want uint64
+	}{
+		{"sent", protocol.Ack{Epoch: 2, Tick: 12}, 12},
+		{"older room", protocol.Ack{Epoch: 1, Tick: 12}, 0},
+		{"not sent yet", protocol.Ack{Epoch: 2, Tick: 20}, 0},
+		{"before the join", protocol.Ack{Epoch: 2, Tick: 5}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, p := pausedRoom()
+			m := r.members[p.UUID]
+			m.epoch, m.first, m.sent = 2, 10, 15
+			r.handleInput(input{id: p.UUID, msg: &tt.ack})
+			if m.ack != tt.want {
+				t.Errorf("ack is %d, want %d", m.ack, tt.want)
+			}
+		})
+	}
+}
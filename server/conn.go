@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	guuid "github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// sendQueueSize is the number of messages buffered per client. Snapshots
+// are full game states, so a short queue is enough and keeps latency low.
+const sendQueueSize = 8
+
+const writeWait = 2 * time.Second
+
+// maxLag is how long a client may keep dropping snapshots before it is
+// disconnected.
+const maxLag = 3 * time.Second
+
+// CustomConn wraps a websocket with its own writer goroutine. Only the
+// writer touches Conn for writing, everybody else goes through
+// SendMessage for snapshots or SendControl for everything else.
+type CustomConn struct {
+	Conn *websocket.Conn
+	ID   guuid.UUID
+	// Encoding is the snapshot encoding the client asked for.
+	Encoding string
+
+	send chan outbound
+	// wake tells the writer about new control messages
+	wake chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	mu          sync.Mutex
+	behindSince time.Time
+	control     []outbound
+}
+
+// outbound is a queued websocket message with its message type.
+type outbound struct {
+	typ  int
+	data []byte
+}
+
+func NewCustomConn(id guuid.UUID, c *websocket.Conn, encoding string) *CustomConn {
+	cc := &CustomConn{
+		ID:       id,
+		Conn:     c,
+		Encoding: encoding,
+		send:     make(chan outbound, sendQueueSize),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go cc.writePump()
+	return cc
+}
+
+// Send queues a text control message, see SendControl.
+func (c *CustomConn) Send(msg []byte) bool {
+	return c.SendControl(websocket.TextMessage, msg)
+}
+
+// SendControl queues a one-off message like the map or a reply. Those are
+// never dropped and go out ahead of the queued snapshots. It reports
+// whether msg was queued, which it is unless the connection is closed.
+func (c *CustomConn) SendControl(typ int, msg []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	c.control = append(c.control, outbound{typ: typ, data: msg})
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// nextControl takes the oldest queued control message.
+func (c *CustomConn) nextControl() (outbound, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.control) == 0 {
+		return outbound{}, false
+	}
+	out := c.control[0]
+	c.control = c.control[1:]
+	return out, true
+}
+
+// SendMessage queues msg without blocking. It is meant for snapshots:
+// when the queue is full the oldest message is stale anyway and gets
+// dropped for msg; a client that stays behind for longer than maxLag is
+// disconnected. SendMessage reports whether msg was queued.
+func (c *CustomConn) SendMessage(typ int, msg []byte) bool {
+	out := outbound{typ: typ, data: msg}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- out:
+		c.behindSince = time.Time{}
+		return true
+	default:
+	}
+
+	if c.behindSince.IsZero() {
+		c.behindSince = time.Now()
+	} else if time.Since(c.behindSince) > maxLag {
+		fmt.Printf("connection %s is too slow, disconnecting\n", c.ID.String())
+		c.Close()
+		return false
+	}
+	select {
+	case <-c.send:
+	default:
+	}
+	select {
+	case c.send <- out:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops the writer and closes the websocket, which in turn ends the
+// read loop in Connect. It is safe to call more than once.
+func (c *CustomConn) Close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.Conn.Close()
+	})
+}
+
+func (c *CustomConn) writePump() {
+	for {
+		msg, ok := c.nextControl()
+		if !ok {
+			select {
+			case <-c.done:
+				return
+			case <-c.wake:
+				continue
+			case msg = <-c.send:
+			}
+		}
+		c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+		err := c.Conn.WriteMessage(msg.typ, msg.data)
+		if err != nil {
+			fmt.Println(err.Error())
+			c.Close()
+			return
+		}
+	}
+}
@@ -1,14 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
-	"os"
-	"strings"
 	"time"
 
+	"github.com/maxxxlounge/websocket/bot"
 	"github.com/maxxxlounge/websocket/game"
+	"github.com/maxxxlounge/websocket/protocol"
+	"github.com/maxxxlounge/websocket/room"
 
 	guuid "github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -16,21 +17,54 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-type CustomConn struct {
-	Conn *websocket.Conn
-	ID   guuid.UUID
-}
-
-var mainGame *game.Game
-var connections map[guuid.UUID]*CustomConn
+var lobby *room.Lobby
 
 func main() {
-	enablelog := false
-	if len(os.Args) > 1 && os.Args[1] == "log" {
-		enablelog = true
+	tickRate := flag.Int("tickrate", game.DefaultTickRate, "simulation ticks per second")
+	maxPlayers := flag.Int("maxplayers", 16, "default player cap of a room")
+	view := flag.Float64("view", 0, "only send what is within this distance of a player, 0 for the whole arena")
+	mapFile := flag.String("map", "", "JSON map file of the arena, the empty default arena if not set")
+	weaponFile := flag.String("weapons", "", "JSON weapon definitions, the built-in weapons if not set")
+	bots := flag.Int("bots", 0, "fill rooms with bots up to this many players, 0 for none")
+	difficulty := flag.String("difficulty", bot.Normal.Name, "bot difficulty: easy, normal or hard")
+	idle := flag.Duration("idle", 30*time.Second, "close rooms that were empty for this long")
+	match := game.DefaultMatchConfig()
+	flag.IntVar(&match.MinPlayers, "minplayers", match.MinPlayers, "players needed to start a match")
+	flag.IntVar(&match.ScoreLimit, "scorelimit", match.ScoreLimit, "score that ends a match, 0 for none")
+	flag.Float64Var(&match.TimeLimit, "timelimit", match.TimeLimit, "match length in seconds, 0 for none")
+	flag.IntVar(&match.Teams, "teams", match.Teams, "number of teams, 0 for free for all")
+	flag.BoolVar(&match.FriendlyFire, "friendlyfire", match.FriendlyFire, "let teammates hurt each other")
+	flag.StringVar(&match.Mode, "mode", match.Mode, "game mode: deathmatch, ctf, koth or lastpig")
+	edge := flag.String("edge", string(match.Edge), "arena edges: wall, bounce or wrap")
+	flag.Parse()
+	enablelog := flag.Arg(0) == "log"
+	l := log.New()
+	var err error
+	match.Edge, err = game.ParseEdgeMode(*edge)
+	if err != nil {
+		l.Fatal(err)
+	}
+	if _, err := game.NewGameMode(match.Mode); err != nil {
+		l.Fatal(err)
+	}
+	botDifficulty, err := bot.ParseDifficulty(*difficulty)
+	if err != nil {
+		l.Fatal(err)
+	}
+	arena := game.DefaultMap()
+	if *mapFile != "" {
+		arena, err = game.LoadMap(*mapFile)
+		if err != nil {
+			l.Fatal(err)
+		}
+	}
+	weapons := game.DefaultWeapons()
+	if *weaponFile != "" {
+		weapons, err = game.LoadWeapons(*weaponFile)
+		if err != nil {
+			l.Fatal(err)
+		}
 	}
-	l := &log.Logger{}
-	connections = make(map[guuid.UUID]*CustomConn)
 	//server
 	r := mux.NewRouter()
 	srv := &http.Server{
@@ -45,11 +79,18 @@ func main() {
 		Connect(w, r, l)
 	})
 
-	mainGame = game.New()
-
-	go func() {
-		Execute(enablelog)
-	}()
+	lobby = room.NewLobby(room.Config{
+		TickRate:      *tickRate,
+		MaxPlayers:    *maxPlayers,
+		Match:         match,
+		Map:           arena,
+		Weapons:       weapons,
+		IdleTimeout:   *idle,
+		ViewRadius:    *view,
+		Bots:          *bots,
+		BotDifficulty: botDifficulty,
+		Log:           enablelog,
+	})
 
 	fmt.Printf("start listening on %s\n", srv.Addr)
 	l.Fatal(srv.ListenAndServe())
@@ -64,25 +105,31 @@ func Connect(w http.ResponseWriter, r *http.Request, l *log.Logger) {
 			return true
 		},
 	}
+	encoding := r.URL.Query().Get("encoding")
+	switch encoding {
+	case "":
+		encoding = protocol.EncodingJSON
+	case protocol.EncodingJSON, protocol.EncodingBinary:
+	default:
+		http.Error(w, "unknown encoding "+encoding, http.StatusBadRequest)
+		return
+	}
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		l.Fatal(err)
+		l.Error(err)
 		return
 	}
 	g := guuid.New()
-	cc := CustomConn{
-		ID:   g,
-		Conn: c,
-	}
-	connections[g] = &cc
-	fmt.Printf("incoming connection %s from %s\n", g.String(), cc.Conn.LocalAddr().String())
-	defer func(conn *websocket.Conn, g guuid.UUID, game *game.Game) {
-		delete(connections, g)
-		game.DeletePlayer(g)
-		c.Close()
-	}(c, g, mainGame)
-	p := mainGame.NewPlayer(g)
+	cc := NewCustomConn(g, c, encoding)
+	fmt.Printf("incoming connection %s from %s\n", g.String(), cc.Conn.RemoteAddr().String())
+	// current is the room of the connection, only this goroutine touches it.
+	var current *room.Room
+	defer func(cc *CustomConn) {
+		if current != nil {
+			current.Leave(cc.ID)
+		}
+		cc.Close()
+	}(cc)
 
 	for {
 		mType, m, err := cc.Conn.ReadMessage()
@@ -93,117 +140,77 @@ func Connect(w http.ResponseWriter, r *http.Request, l *log.Logger) {
 		if mType != websocket.TextMessage {
 			continue
 		}
-		msg := string(m)
-		//fmt.Println(cc.ID.String() + " send message " + msg)
-		if strings.Contains(msg, "setup|") {
-			p.Name = strings.Replace(msg, "setup|", "", -1)
+		msg, err := protocol.Decode(m)
+		if err == nil {
+			current, err = handleMessage(cc, current, msg)
 		}
-		switch string(m) {
-		case "pause":
-			p.Status = game.Pause
-			break
-		case "resume":
-			p.Status = game.Resume
-			break
-		case "Leftrelease":
-			p.Left = false
-			break
-		case "Leftpressed":
-			p.Left = true
-			break
-		case "LeftUprelease":
-			p.Left = false
-			p.Up = false
-			break
-		case "LeftUppressed":
-			p.Left = true
-			p.Up = true
-			break
-		case "LeftDownrelease":
-			p.Left = false
-			p.Down = false
-			break
-		case "LeftDownpressed":
-			p.Left = true
-			p.Down = true
-			break
-		case "Rightrelease":
-			p.Right = false
-			break
-		case "Rightpressed":
-			p.Right = true
-			break
-		case "RightUprelease":
-			p.Right = false
-			p.Up = false
-			break
-		case "RightUppressed":
-			p.Right = true
-			p.Up = true
-			break
-		case "RightDownrelease":
-			p.Right = false
-			p.Down = false
-			break
-		case "RightDownpressed":
-			p.Right = true
-			p.Down = true
-			break
-		case "Downrelease":
-			p.Down = false
-			break
-		case "Downpressed":
-			p.Down = true
-			break
-		case "Uprelease":
-			p.Up = false
-			break
-		case "Uppressed":
-			p.Up = true
-			break
-		case "Firepressed":
-			p.Fire = true
-			break
-		case "Firerelease":
-			p.Fire = false
-			break
+		if err != nil {
+			reply, _ := protocol.Encode(protocol.NewError(err))
+			cc.Send(reply)
 		}
-
 	}
 }
 
-func Execute(enablelog bool) {
-	fmt.Println("executing")
-	last := time.Now()
-	for {
-		dt := time.Since(last).Seconds()
-		last = time.Now()
-		mainGame.MovePlayers(dt)
-		mainGame.MoveBullets()
-		mainGame.Collision()
-		for _, c := range connections {
-			p := mainGame.GetPlayer(c.ID)
-			if p == nil {
-				return
-			}
-			if p.Status == game.Pause {
-				continue
-			}
-			mainGame.SetYou(c.ID)
-			msg, err := json.Marshal(mainGame)
+// handleMessage serves the lobby messages and forwards everything else to
+// the room of the connection. Clients that never joined a room are put
+// into one on their first game message. It returns the new room.
+func handleMessage(cc *CustomConn, current *room.Room, msg interface{}) (*room.Room, error) {
+	var next *room.Room
+	var err error
+	switch m := msg.(type) {
+	case *protocol.ListRooms:
+		reply, err := protocol.Encode(protocol.NewRooms(lobby.List()))
+		if err != nil {
+			return current, err
+		}
+		cc.Send(reply)
+		return current, nil
+	case *protocol.LeaveRoom:
+		if current != nil {
+			current.Leave(cc.ID)
+		}
+		return nil, nil
+	case *protocol.CreateRoom:
+		next = lobby.Create(m.Name, m.MaxPlayers)
+	case *protocol.JoinRoom:
+		if m.Room != "" {
+			next, err = lobby.Get(m.Room)
 			if err != nil {
-				fmt.Println(err.Error())
+				return current, err
 			}
-			//msg := "send message to conn " + u.String()
-			err = c.Conn.WriteMessage(websocket.TextMessage, msg) //[]byte(msg))
+		} else if current != nil {
+			// any room will do, the current one included
+			next = current
+		}
+	default:
+		if current == nil {
+			current, err = lobby.QuickJoin(cc.ID, cc, cc.Encoding)
 			if err != nil {
-				fmt.Println(err.Error())
-			}
-			if enablelog {
-				fmt.Printf("%v\n", string(msg))
+				return nil, err
 			}
 		}
+		current.Input(cc.ID, msg)
+		return current, nil
+	}
 
-		time.Sleep(10 * time.Millisecond)
+	if current == nil || current != next {
+		if next == nil {
+			next, err = lobby.QuickJoin(cc.ID, cc, cc.Encoding)
+		} else {
+			err = next.Join(cc.ID, cc, cc.Encoding)
+		}
+		if err != nil {
+			// a full or closed room keeps the client where it was
+			return current, err
+		}
+		if current != nil {
+			current.Leave(cc.ID)
+		}
+	}
+	reply, err := protocol.Encode(protocol.NewJoined(next.Info()))
+	if err != nil {
+		return next, err
 	}
+	cc.Send(reply)
+	return next, nil
 }